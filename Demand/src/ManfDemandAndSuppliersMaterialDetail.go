@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
+	"regexp"
 	"time"
-	"strconv"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
 )
@@ -15,26 +17,204 @@ type PurchaseOrder struct {
 	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
 	SupplierCode string `json:"supplierCode"`
 	SupplierLocation string `json:"supplierLocation"`
+	Lines []PurchaseOrderLine `json:"lines"`
+	Status string `json:"status"`
+	StatusReason string `json:"statusReason"`
+	Version int `json:"version"`
+	ChangeOrders []ChangeOrder `json:"changeOrders"`
+	BlanketOrderNumber string `json:"blanketOrderNumber"`
 	IsPurchaseOrderObject bool `json:"isPurchaseOrderObject"`
 }
 
+// states of a purchase order, closed and cancelled are final
+const (
+	purchaseOrderStatusOpen = "Open"
+	purchaseOrderStatusConfirmed = "Confirmed"
+	purchaseOrderStatusChanged = "Changed"
+	purchaseOrderStatusPartiallyReceived = "PartiallyReceived"
+	purchaseOrderStatusReceived = "Received"
+	purchaseOrderStatusClosed = "Closed"
+	purchaseOrderStatusCancelled = "Cancelled"
+)
+
+// parties of a purchase order which agree on expected dates
+const (
+	partyBuyer = "BUYER"
+	partySupplier = "SUPPLIER"
+)
+
+// states of the expected date of a material, only confirmed dates are used to calculate delays
+const (
+	dateStatusProposed = "Proposed"
+	dateStatusCounterProposed = "CounterProposed"
+	dateStatusConfirmed = "Confirmed"
+)
+
+// states of a change order
+const (
+	changeOrderStatusPending = "Pending"
+	changeOrderStatusAcknowledged = "Acknowledged"
+	changeOrderStatusRejected = "Rejected"
+)
+
+// change of quantities and requested dates of a purchase order, it takes effect once the supplier acknowledged it.
+// The purchase order returns to its previous state when the change order is decided.
+type ChangeOrder struct {
+	ChangeOrderNumber string `json:"changeOrderNumber"`
+	Changes []PurchaseOrderLine `json:"changes"`
+	Reason string `json:"reason"`
+	Status string `json:"status"`
+	PreviousStatus string `json:"previousStatus"`
+	MspId string `json:"mspId"`
+	RequestedOn string `json:"requestedOn"`
+	DecidedOn string `json:"decidedOn"`
+	Comment string `json:"comment"`
+}
+
+// ordered line of a purchase order, a material is ordered on one line only
+type PurchaseOrderLine struct {
+	LineNumber string `json:"lineNumber"`
+	MaterialNumber string `json:"materialNumber"`
+	Quantity string `json:"quantity"`
+	UnitOfMeasure string `json:"unitOfMeasure"`
+	UnitPrice string `json:"unitPrice"`
+	RequestedDate string `json:"requestedDate"`
+}
+
+// framework agreement with a supplier on total quantities of materials at agreed prices for a validity period. Releases
+// are purchase orders which draw down its quantities and, if agreed, its total value.
+type BlanketPurchaseOrder struct {
+	BlanketOrderNumber string `json:"blanketOrderNumber"`
+	SupplierCode string `json:"supplierCode"`
+	SupplierLocation string `json:"supplierLocation"`
+	ValidFrom string `json:"validFrom"`
+	ValidTo string `json:"validTo"`
+	TotalValue string `json:"totalValue"`
+	Lines []PurchaseOrderLine `json:"lines"`
+	Releases []string `json:"releases"`
+	IsBlanketPurchaseOrderObject bool `json:"isBlanketPurchaseOrderObject"`
+}
+
+// agreed line of a blanket purchase order with the quantity released from it
+type BlanketOrderLineBalance struct {
+	LineNumber string `json:"lineNumber"`
+	MaterialNumber string `json:"materialNumber"`
+	UnitOfMeasure string `json:"unitOfMeasure"`
+	UnitPrice string `json:"unitPrice"`
+	Quantity string `json:"quantity"`
+	ReleasedQuantity string `json:"releasedQuantity"`
+	RemainingQuantity string `json:"remainingQuantity"`
+}
+
+// release of a blanket purchase order with the state of its purchase order
+type BlanketOrderRelease struct {
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+	PurchaseOrderStatus string `json:"purchaseOrderStatus"`
+}
+
+// blanket purchase order with its remaining balance, remaining value is only known when a total value is agreed
+type BlanketOrderBalance struct {
+	BlanketOrderNumber string `json:"blanketOrderNumber"`
+	SupplierCode string `json:"supplierCode"`
+	SupplierLocation string `json:"supplierLocation"`
+	ValidFrom string `json:"validFrom"`
+	ValidTo string `json:"validTo"`
+	IsValid bool `json:"isValid"`
+	TotalValue string `json:"totalValue"`
+	ReleasedValue string `json:"releasedValue"`
+	RemainingValue string `json:"remainingValue"`
+	Lines []BlanketOrderLineBalance `json:"lines"`
+	Releases []BlanketOrderRelease `json:"releases"`
+}
+
+// ExpectedDate is the date the supplier confirmed, it is empty while the date is proposed
 type ExpectedMaterialInformation struct {
 	MaterialNumber string `json:"materialNumber"`
 	Ex_PurchaseOrderNumber string `json:"ex_PurchaseOrderNumber"`
 	ExpectedDate string `json:"expectedDate"`
+	ProposedDate string `json:"proposedDate"`
+	ProposedBy string `json:"proposedBy"`
+	ProposalReason string `json:"proposalReason"`
+	DateStatus string `json:"dateStatus"`
+	ConfirmedOn string `json:"confirmedOn"`
+	OrderedQuantity string `json:"orderedQuantity"`
+	UnitPrice string `json:"unitPrice"`
+	Reschedules []RescheduleEvent `json:"reschedules"`
+	PendingReschedule *RescheduleEvent `json:"pendingReschedule"`
 	IsExpectedMaterialInfoObject bool `json:"isExpectedMaterialInfoObject"`
 }
 
+// change of a confirmed expected date, proposed by one party and confirmed by the other one. The invoice chaincode
+// decides with the penalty contract whether it moves the date delays are measured from
+type RescheduleEvent struct {
+	OldExpectedDate string `json:"oldExpectedDate"`
+	NewExpectedDate string `json:"newExpectedDate"`
+	RequestedBy string `json:"requestedBy"`
+	MspId string `json:"mspId"`
+	Reason string `json:"reason"`
+	RescheduledOn string `json:"rescheduledOn"`
+}
+
 type ActualMaterialInformation struct {
 	MaterialNumber string `json:"materialNumber"`
 	Ac_PurchaseOrderNumber string `json:"ac_PurchaseOrderNumber"`
 	DelayReason string `json:"delayReason"`
 	ActualDate string `json:"actualDate"`
+	ReceiptNumber string `json:"receiptNumber"`
+	ReceivedQuantity string `json:"receivedQuantity"`
+}
+
+// goods receipt of a material as returned to the invoice chaincode
+type MaterialReceipt struct {
+	ReceiptNumber string `json:"receiptNumber"`
+	ActualDate string `json:"actualDate"`
+	ReceivedQuantity string `json:"receivedQuantity"`
+	DelayReason string `json:"delayReason"`
+}
+
+// material of a purchase order with its receipts as returned to the invoice chaincode, which matches invoices against it
+type PurchaseOrderMaterial struct {
+	PurchaseOrderNumber string `json:"purchaseOrderNumber"`
+	SupplierCode string `json:"supplierCode"`
+	MaterialNumber string `json:"materialNumber"`
+	ExpectedDate string `json:"expectedDate"`
+	ActualDate string `json:"actualDate"`
+	DelayReason string `json:"delayReason"`
+	LineNumber string `json:"lineNumber"`
+	OrderedQuantity string `json:"orderedQuantity"`
+	UnitOfMeasure string `json:"unitOfMeasure"`
+	UnitPrice string `json:"unitPrice"`
+	ReceivedQuantity string `json:"receivedQuantity"`
+	IsComplete bool `json:"isComplete"`
+	Receipts []MaterialReceipt `json:"receipts"`
+	Reschedules []RescheduleEvent `json:"reschedules"`
 }
 	
+// response of getSupplier on the invoice chaincode, which registers the organizations of suppliers
+type Supplier struct {
+	SupplierCode string `json:"supplierCode"`
+	MspId string `json:"mspId"`
+}
+
+// response of getInvoiceAmountById on the invoice chaincode, which owns the penalty schedules and contracts
 type Invoice struct {
-	InvoiceAmount float64 `json:"invoiceAmount"`
-	DelayPenalty float64 `json:"delayPenalty"`
+	InvoiceAmount string `json:"invoiceAmount"`
+	InvoiceStatus string `json:"invoiceStatus"`
+	Status string `json:"status"`
+	State string `json:"state"`
+	DelayPenalty string `json:"delayPenalty"`
+	ContractId string `json:"contractId"`
+	PenaltyBaselineDate string `json:"penaltyBaselineDate"`
+	Exemption string `json:"exemption"`
+	WaivedDelayPenalty string `json:"waivedDelayPenalty"`
+	WaiverStatus string `json:"waiverStatus"`
+	WaiverDelayPenalty string `json:"waiverDelayPenalty"`
+	DisputeStatus string `json:"disputeStatus"`
+	CreditNoteNumber string `json:"creditNoteNumber"`
+	CreditNoteStatus string `json:"creditNoteStatus"`
+	ReportingCurrency string `json:"reportingCurrency"`
+	ReportingInvoiceAmount string `json:"reportingInvoiceAmount"`
+	ReportingDelayPenalty string `json:"reportingDelayPenalty"`
 }
 
 type TrackOrder struct {
@@ -48,6 +228,54 @@ type TrackOrder struct {
 	TrackOrderState string `json:"trackOrderState"`
 }
 
+// chaincode config passed as json to Init on instantiate or upgrade, e.g.
+// {"invoiceChaincodeName":"invoice","buyerMspIds":["BuyerMSP"]}
+// The invoice chaincode calculates invoice amount and delay penalty and registers the organizations of suppliers,
+// buyer organizations are the ones listed here.
+type ChaincodeConfig struct {
+	InvoiceChaincodeName string `json:"invoiceChaincodeName"`
+	BuyerMspIds []string `json:"buyerMspIds"`
+}
+
+// format of all dates handled by the chaincode
+const timeFormat = "01/02/2006"
+
+// quantities and prices are positive decimal numbers
+var decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+/*
+ * Parse a quantity or price, returns false when it is no decimal number greater than zero.
+ */
+func parsePositiveDecimal(value string) (*big.Rat, bool) {
+	if !decimalPattern.MatchString(value) {
+		return nil, false
+	}
+	decimal, _ := new(big.Rat).SetString(value)
+	return decimal, decimal.Sign() > 0
+}
+
+/*
+ * Check if two decimal numbers are equal, e.g. 10 and 10.0.
+ */
+func isSameDecimal(value string, other string) bool {
+	decimal, ok := new(big.Rat).SetString(value)
+	otherDecimal, otherOk := new(big.Rat).SetString(other)
+	return ok && otherOk && decimal.Cmp(otherDecimal) == 0
+}
+
+/*
+ * Format a quantity in plain decimal notation with as many decimal places as needed to be exact.
+ */
+func quantityString(quantity *big.Rat) string {
+	scaled := new(big.Rat).Set(quantity)
+	places := 0
+	for !scaled.IsInt() && places < 32 {
+		scaled.Mul(scaled,big.NewRat(10,1))
+		places++
+	}
+	return quantity.FloatString(places)
+}
+
 func Success(rc int32, message string, payload []byte) peer.Response {
 	return peer.Response{
 		Status:  rc,
@@ -78,9 +306,65 @@ func main() {
 }
 
 func (cc *PurchaseOrder) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	
+	// optional 1st parameter - chaincode config as json, the stored config is kept when it is not passed
+	if _, args := stub.GetFunctionAndParameters(); len(args) > 0 && args[0] != "" {
+		return putChaincodeConfig(stub,args[0])
+	}
+	
 	return Success(http.StatusOK, "OK", nil)
 }
 
+/*
+ * Read chaincode config from blockchain, empty config if Init was never called with one.
+ */
+func getChaincodeConfig(stub shim.ChaincodeStubInterface) (ChaincodeConfig, error) {
+	var config ChaincodeConfig
+	
+	configInBytes, err := stub.GetState("CONFIG")
+	if err != nil || configInBytes == nil {
+		return config, err
+	}
+	
+	err = json.Unmarshal(configInBytes,&config)
+	return config, err
+}
+
+/*
+ * Validate and write chaincode config passed to Init.
+ */
+func putChaincodeConfig(stub shim.ChaincodeStubInterface, configInJson string) peer.Response {
+	var config ChaincodeConfig
+	if err := json.Unmarshal([]byte(configInJson),&config); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid config: "+err.Error())
+	}
+	
+	// convert to byte
+	configInBytes, _ := json.Marshal(config)
+	
+	// write config to BC
+	if err := stub.PutState("CONFIG", configInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK, "OK", configInBytes)
+}
+
+/*
+ * Invoke a function of the invoice chaincode configured in the chaincode config.
+ */
+func invokeInvoiceChaincode(stub shim.ChaincodeStubInterface, args ...string) peer.Response {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if config.InvoiceChaincodeName == "" {
+		return Error(http.StatusInternalServerError, "invoiceChaincodeName is missing in chaincode config")
+	}
+	
+	return stub.InvokeChaincode(config.InvoiceChaincodeName,toChaincodeArgs(args...),"")
+}
+
 func (cc *PurchaseOrder) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 
 	function, args := stub.GetFunctionAndParameters()
@@ -99,26 +383,125 @@ func (cc *PurchaseOrder) Invoke(stub shim.ChaincodeStubInterface) peer.Response
 			return cc.getAllPurchaseOrder(stub,args)
 		case "getAllMaterialInformation":
 			return cc.getAllMaterialInformation(stub,args)
+		case "getPurchaseOrderMaterial":
+			return cc.getPurchaseOrderMaterial(stub,args)
+		case "issueCreditNote":
+			return cc.issueCreditNote(stub,args)
+		case "confirmPurchaseOrder":
+			return cc.confirmPurchaseOrder(stub,args)
+		case "createChangeOrder":
+			return cc.createChangeOrder(stub,args)
+		case "acknowledgeChangeOrder":
+			return cc.acknowledgeChangeOrder(stub,args)
+		case "cancelPurchaseOrder":
+			return cc.cancelPurchaseOrder(stub,args)
+		case "closePurchaseOrder":
+			return cc.closePurchaseOrder(stub,args)
+		case "proposeExpectedDate":
+			return cc.proposeExpectedDate(stub,args)
+		case "confirmExpectedDate":
+			return cc.confirmExpectedDate(stub,args)
+		case "counterProposeExpectedDate":
+			return cc.counterProposeExpectedDate(stub,args)
+		case "rescheduleExpectedDate":
+			return cc.rescheduleExpectedDate(stub,args)
+		case "confirmReschedule":
+			return cc.confirmReschedule(stub,args)
+		case "createBlanketPurchaseOrder":
+			return cc.createBlanketPurchaseOrder(stub,args)
+		case "createReleaseOrder":
+			return cc.createReleaseOrder(stub,args)
+		case "getBlanketPurchaseOrder":
+			return cc.getBlanketPurchaseOrder(stub,args)
 		default:
-			return Error(http.StatusNotImplemented, "Invalid method! Valid methods are 'createPurchaseOrder|createExpectedMaterialInformation|createActualMaterialInformation|getAllPurchaseOrder|getAllMaterialInformation|createMaterialTracking'!")
+			return Error(http.StatusNotImplemented, "Invalid method! Valid methods are 'createPurchaseOrder|createExpectedMaterialInformation|createActualMaterialInformation|getAllPurchaseOrder|getAllMaterialInformation|createMaterialTracking|getPurchaseOrderMaterial|issueCreditNote|confirmPurchaseOrder|createChangeOrder|acknowledgeChangeOrder|cancelPurchaseOrder|closePurchaseOrder|proposeExpectedDate|confirmExpectedDate|counterProposeExpectedDate|rescheduleExpectedDate|confirmReschedule|createBlanketPurchaseOrder|createReleaseOrder|getBlanketPurchaseOrder'!")
+	}
+}
+
+/*
+ * Parse lines of a purchase order passed as json and validate them, lines without number are numbered in order.
+ */
+func parsePurchaseOrderLines(linesInJson string) ([]PurchaseOrderLine, error) {
+	var lines []PurchaseOrderLine
+	if err := json.Unmarshal([]byte(linesInJson), &lines); err != nil {
+		return nil, err
+	}
+	
+	orderedMaterials := map[string]bool{}
+	for i := range lines {
+		line := &lines[i]
+		if line.LineNumber == "" {
+			line.LineNumber = fmt.Sprintf("%d", i+1)
+		}
+		if line.MaterialNumber == "" {
+			return nil, fmt.Errorf("line %s: materialNumber is mandatory", line.LineNumber)
+		}
+		if orderedMaterials[line.MaterialNumber] {
+			return nil, fmt.Errorf("line %s: material %s is ordered on another line", line.LineNumber, line.MaterialNumber)
+		}
+		orderedMaterials[line.MaterialNumber] = true
+		
+		if _, ok := parsePositiveDecimal(line.Quantity); !ok {
+			return nil, fmt.Errorf("line %s: quantity must be a decimal number greater than 0", line.LineNumber)
+		}
+		if _, ok := parsePositiveDecimal(line.UnitPrice); line.UnitPrice != "" && !ok {
+			return nil, fmt.Errorf("line %s: unitPrice must be a decimal number greater than 0", line.LineNumber)
+		}
+		if _, err := time.Parse(timeFormat, line.RequestedDate); line.RequestedDate != "" && err != nil {
+			return nil, fmt.Errorf("line %s: requestedDate must be in format %s", line.LineNumber, timeFormat)
+		}
+	}
+	
+	return lines, nil
+}
+
+/*
+ * Get the line a material is ordered on, nil when it is not ordered on the purchase order.
+ */
+func (purchaseOrderObject PurchaseOrder) lineForMaterial(materialNumber string) *PurchaseOrderLine {
+	for i := range purchaseOrderObject.Lines {
+		if purchaseOrderObject.Lines[i].MaterialNumber == materialNumber {
+			return &purchaseOrderObject.Lines[i]
+		}
 	}
+	return nil
 }
 
+/*
+ * Function to create a purchase order.
+ * 1st - purchase order #, 2nd - supplier code, 3rd - supplier location
+ * optional: 4th - lines as json e.g. [{"materialNumber":"M1","quantity":"100","unitOfMeasure":"PC","unitPrice":"12.50","requestedDate":"03/31/2020"}],
+ * expected material information of a purchase order with lines is only accepted for materials ordered on a line
+ */
 func (cc *PurchaseOrder) createPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 3 {
+	if len(args) < 3 || len(args) > 4 {
 		return Error(http.StatusNotAcceptable, "Invalid parameters!")
 	}
 	
+	lines := []PurchaseOrderLine{}
+	if len(args) > 3 && args[3] != "" {
+		parsedLines, err := parsePurchaseOrderLines(args[3])
+		if err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid purchase order lines: "+err.Error())
+		}
+		lines = parsedLines
+	}
+	
 	// Check if purchase order already exists
 	if validateValue, validateErr := stub.GetState(args[0]); validateErr != nil || validateValue != nil {
 		return Error(http.StatusConflict, "Purchase order already exists")
 	}
 	
+	// lines are written with the purchase order in one state entry, it is open until the supplier confirms it
 	purchaseOrderObject := &PurchaseOrder{
 		PurchaseOrderNumber: args[0],
 		SupplierCode: args[1],
 		SupplierLocation: args[2],
-		isPurchaseOrderObject: true,
+		Lines: lines,
+		Status: purchaseOrderStatusOpen,
+		Version: 1,
+		ChangeOrders: []ChangeOrder{},
+		IsPurchaseOrderObject: true,
 	}	
 
 	// convert to byte
@@ -132,284 +515,2224 @@ func (cc *PurchaseOrder) createPurchaseOrder(stub shim.ChaincodeStubInterface, a
 	return Success(http.StatusCreated,"Purchase Order Created Successsfully!", nil)
 }
 
-func (cc *PurchaseOrder) createExpectedMaterialInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+/*
+ * Get the date of the transaction in the format of the chaincode.
+ */
+func getTxDate(stub shim.ChaincodeStubInterface) (string, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", err
+	}
+	return time.Unix(txTimestamp.Seconds,int64(txTimestamp.Nanos)).UTC().Format(timeFormat), nil
+}
 
-	if len(args) != 3 {
-		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+/*
+ * Read purchase order from blockchain, returns nil purchase order when it does not exist.
+ */
+func getPurchaseOrderById(stub shim.ChaincodeStubInterface, purchaseOrderNumber string) (*PurchaseOrder, error) {
+	purchaseOrderInBytes, err := stub.GetState(purchaseOrderNumber)
+	if err != nil || purchaseOrderInBytes == nil {
+		return nil, err
 	}
 	
-	// Check if material expected date info already exists
-	if validateValue, validateErr := stub.GetState("Ex-"+args[0]+"-"+args[1]); validateErr != nil || validateValue != nil {
-		return Error(http.StatusConflict, "Expected Date for purchase order "+args[1]+" and material number "+args[0]+" already exists!")
+	var purchaseOrderObject PurchaseOrder
+	if err := json.Unmarshal(purchaseOrderInBytes,&purchaseOrderObject); err != nil {
+		return nil, err
 	}
-	
-	expectedMaterialInformationObject := &ExpectedMaterialInformation{
-		MaterialNumber: args[0],
-		Ex_PurchaseOrderNumber: args[1],
-		ExpectedDate: args[2],
-		IsExpectedMaterialInfoObject: true,
-	}	
+	return &purchaseOrderObject, nil
+}
 
+/*
+ * Write purchase order to blockchain.
+ */
+func putPurchaseOrder(stub shim.ChaincodeStubInterface, purchaseOrderObject PurchaseOrder) error {
+	purchaseOrderObject.IsPurchaseOrderObject = true
+	
 	// convert to byte
-	expectedMaterialInformationObjectInBytes, _ := json.Marshal(expectedMaterialInformationObject)
+	purchaseOrderObjectInBytes, _ := json.Marshal(purchaseOrderObject)
+	
+	// write PurchaseOrder and details to BC
+	return stub.PutState(purchaseOrderObject.PurchaseOrderNumber, purchaseOrderObjectInBytes)
+}
 
-	// write material info to BC
-	if err := stub.PutState("Ex-"+args[0]+"-"+args[1], expectedMaterialInformationObjectInBytes); err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
+/*
+ * Get the state of the purchase order, purchase orders created before states were introduced are open.
+ */
+func (purchaseOrderObject PurchaseOrder) status() string {
+	if purchaseOrderObject.Status == "" {
+		return purchaseOrderStatusOpen
 	}
-
-	return Success(http.StatusCreated,"Material's expected delivery date information created successsfully!", nil)
+	return purchaseOrderObject.Status
 }
 
-func (cc *PurchaseOrder) createActualMaterialInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+/*
+ * Check if materials may still be expected and received on the purchase order.
+ */
+func (purchaseOrderObject PurchaseOrder) isActive() bool {
+	status := purchaseOrderObject.status()
+	return status != purchaseOrderStatusClosed && status != purchaseOrderStatusCancelled
+}
 
-	if len(args) != 4 {
-		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+/*
+ * Get the change order waiting for the supplier's acknowledgement, nil when there is none.
+ */
+func (purchaseOrderObject PurchaseOrder) pendingChangeOrder() *ChangeOrder {
+	for i := range purchaseOrderObject.ChangeOrders {
+		if purchaseOrderObject.ChangeOrders[i].Status == changeOrderStatusPending {
+			return &purchaseOrderObject.ChangeOrders[i]
+		}
 	}
+	return nil
+}
+
+/*
+ * Set the receipt state of the purchase order after a goods receipt of a material, it is received once all its materials
+ * are delivered completely. Rich queries do not see the receipt written in this transaction, so the receipts of the
+ * received material are passed. While a change order is pending the state is restored once it is decided.
+ */
+func updatePurchaseOrderReceiptStatus(stub shim.ChaincodeStubInterface, purchaseOrderObject PurchaseOrder, materialNumber string, receivedMaterial materialReceipts) error {
+	isReceived := receivedMaterial.isComplete
 	
-	// Check if raw material expected date info already exists
-	if validateValue, validateErr := stub.GetState("Ac-"+args[0]+"-"+args[1]); validateErr != nil || validateValue != nil {
-		return Error(http.StatusConflict, "Actual Date for purchase order "+args[1]+" and material number "+args[0]+" already exists!")
+	queryString := fmt.Sprintf("{\"selector\":{\"isExpectedMaterialInfoObject\":true,\"ex_PurchaseOrderNumber\":\""+purchaseOrderObject.PurchaseOrderNumber+"\"}}")
+	expectedPartResultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return err
 	}
+	defer expectedPartResultsIterator.Close()
 	
-	actualMaterialInformationObject := &ActualMaterialInformation{
-		MaterialNumber: args[0],
-		Ac_PurchaseOrderNumber: args[1],
-		ActualDate: args[2],
-		DelayReason: args[3],
-	}	
-
-	// convert to byte
-	actualMaterialInformationObjectInBytes, _ := json.Marshal(actualMaterialInformationObject)
-
-	// write raw material info to BC
-	if err := stub.PutState("Ac-"+args[0]+"-"+args[1], actualMaterialInformationObjectInBytes); err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
+	expectedMaterials := map[string]bool{materialNumber: true}
+	for expectedPartResultsIterator.HasNext() {
+		expectedPartResponse, err := expectedPartResultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		
+		var expectedMaterialInformation ExpectedMaterialInformation
+		json.Unmarshal(expectedPartResponse.Value,&expectedMaterialInformation)
+		if expectedMaterials[expectedMaterialInformation.MaterialNumber] {
+			continue
+		}
+		expectedMaterials[expectedMaterialInformation.MaterialNumber] = true
+		
+		receipts := getActualMaterialReceipts(stub,purchaseOrderObject.PurchaseOrderNumber,expectedMaterialInformation.MaterialNumber)
+		if len(receipts) == 0 || !newMaterialReceipts(expectedMaterialInformation,receipts).isComplete {
+			isReceived = false
+		}
 	}
-
-	return Success(http.StatusCreated,"Material's actual delivery date information created successsfully!", nil)
-}
-
-func (cc *PurchaseOrder) createMaterialTracking(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	
-	if len(args) != 8 {
-		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	// lines without expected material information are not delivered yet
+	for _, line := range purchaseOrderObject.Lines {
+		if !expectedMaterials[line.MaterialNumber] {
+			isReceived = false
+		}
 	}
 	
-	// Check if raw material expected date info already exists
-	if validateValue, validateErr := stub.GetState("TR-"+args[0]); validateErr != nil || validateValue != nil {
-		return Error(http.StatusConflict, "Tracking Number already exists")
-	}
-
-	trackOrderObject := &TrackOrder{
-		TrackingId: args[0],
-		TrackMaterialNumber: args[1],
-		TrackPurchaseOrderNumber: args[2],
-		SupplierFacilityName: args[3],
-		Timestamp: args[4],
-		TrackStatus: args[5],
-		TrackOrderState: args[6],
-		TrackOrderReason: args[7],
+	status := purchaseOrderStatusPartiallyReceived
+	if isReceived {
+		status = purchaseOrderStatusReceived
 	}
 	
-	// convert to byte
-	trackOrderObjectInBytes, _ := json.Marshal(trackOrderObject)
-
-	// write raw material info to BC
-	if err := stub.PutState("TR-"+args[0], trackOrderObjectInBytes); err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
+	if changeOrder := purchaseOrderObject.pendingChangeOrder(); changeOrder != nil {
+		changeOrder.PreviousStatus = status
+	} else {
+		purchaseOrderObject.Status = status
 	}
-
-	return Success(http.StatusCreated,"Tracking Information Created Successsfully!", nil)
+	
+	return putPurchaseOrder(stub,purchaseOrderObject)
 }
 
-
-func (cc *PurchaseOrder) getAllPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	queryStringToGetAllPurchaseOrder := fmt.Sprintf("{\"selector\":{\"isPurchaseOrderObject\":true}}")
-	allPurchaseOrderResults, err := stub.GetQueryResult(queryString)
-	if err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
+/*
+ * Parse changes of a change order passed as json, every change refers to a material of the purchase order and changes
+ * its quantity, its requested date or both.
+ */
+func parseChangeOrderChanges(stub shim.ChaincodeStubInterface, purchaseOrderObject PurchaseOrder, changesInJson string) ([]PurchaseOrderLine, error) {
+	var changes []PurchaseOrderLine
+	if err := json.Unmarshal([]byte(changesInJson), &changes); err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("at least one change is mandatory")
 	}
-	defer allPurchaseOrderResults.Close()
-	
-	// buffer is a JSON array containing QueryResults
-	var buffer bytes.Buffer
-	buffer.WriteString("{ \"values\": [")
-	bArrayMemberAlreadyWritten := false
 	
-	for allPurchaseOrderResults.HasNext() {
-		queryResponse, err1 := allPurchaseOrderResults.Next()
-		if err1 != nil {
-			return shim.Error(err1.Error())
+	for _, change := range changes {
+		if change.Quantity == "" && change.RequestedDate == "" {
+			return nil, fmt.Errorf("material %s: quantity or requestedDate is mandatory", change.MaterialNumber)
 		}
-		
-		// Add a comma before array members, suppress it for the first array member
-		if bArrayMemberAlreadyWritten == true {
-			buffer.WriteString(",")
+		if _, ok := parsePositiveDecimal(change.Quantity); change.Quantity != "" && !ok {
+			return nil, fmt.Errorf("material %s: quantity must be a decimal number greater than 0", change.MaterialNumber)
+		}
+		if _, err := time.Parse(timeFormat, change.RequestedDate); change.RequestedDate != "" && err != nil {
+			return nil, fmt.Errorf("material %s: requestedDate must be in format %s", change.MaterialNumber, timeFormat)
 		}
 		
-		var purchaseOrderObject PurchaseOrder
-		json.Unmarshal(queryResponse.Value,&purchaseOrderObject)
-		buffer.WriteString("{")
-		
-		buffer = generatePurchaseOrderObject(purchaseOrderObject,buffer)
-		buffer.WriteString(",")
-		
-		buffer = getMaterialInformation(purchaseOrderObject.PurchaseOrderNumber,buffer)
-		
-		buffer.WriteString("}")
-		bArrayMemberAlreadyWritten = true
+		// materials of purchase orders without lines are changed on their expected material information
+		if purchaseOrderObject.lineForMaterial(change.MaterialNumber) == nil {
+			if expectedMaterialInBytes, _ := stub.GetState("Ex-"+change.MaterialNumber+"-"+purchaseOrderObject.PurchaseOrderNumber); expectedMaterialInBytes == nil {
+				return nil, fmt.Errorf("material %s is not ordered on purchase order %s", change.MaterialNumber, purchaseOrderObject.PurchaseOrderNumber)
+			}
+		}
 	}
 	
-	buffer.WriteString("]}")
-	return Success(200, "OK", buffer.Bytes())
+	return changes, nil
 }
 
-func generatePurchaseOrderObject(purchaseOrderObject PurchaseOrder,buffer bytes.Buffer) (x bytes.Buffer) {
-	buffer.WriteString("\"purchaseOrderNumber\":")
-	buffer.WriteString("\"")
-	buffer.WriteString(purchaseOrderObject.PurchaseOrderNumber)
-	buffer.WriteString("\"")
-	
-	buffer.WriteString(", \"supplierCode\":")
-	buffer.WriteString("\"")
-	buffer.WriteString(purchaseOrderObject.SupplierCode)
-	buffer.WriteString("\"")
+/*
+ * Apply an acknowledged change to the purchase order line and to the expected material information penalties are
+ * calculated on. The ordered quantity can not be reduced below the quantity received already. A change of a confirmed
+ * expected date is kept in the reschedule history as a reschedule requested by the buyer.
+ */
+func applyChange(stub shim.ChaincodeStubInterface, purchaseOrderObject *PurchaseOrder, changeOrder ChangeOrder, change PurchaseOrderLine, txDate string) error {
+	if line := purchaseOrderObject.lineForMaterial(change.MaterialNumber); line != nil {
+		if change.Quantity != "" {
+			line.Quantity = change.Quantity
+		}
+		if change.RequestedDate != "" {
+			line.RequestedDate = change.RequestedDate
+		}
+	}
 	
-	buffer.WriteString(", \"supplierLocation\":")
-	buffer.WriteString("\"")
-	buffer.WriteString(purchaseOrderObject.SupplierLocation)
-	buffer.WriteString("\"")
+	expectedMaterialInBytes, err := stub.GetState("Ex-"+change.MaterialNumber+"-"+purchaseOrderObject.PurchaseOrderNumber)
+	if err != nil || expectedMaterialInBytes == nil {
+		return err
+	}
 	
-	x = buffer
-	return
-}
-
-func getMaterialInformation(purchaseOrderNumber string,buffer bytes.Buffer)(x bytes.Buffer) {
-	// for material info
-	buffer.WriteString("\"expectedRawMaterialInformation\":")
-	buffer.WriteString("[")
+	var expectedMaterialInformation ExpectedMaterialInformation
+	json.Unmarshal(expectedMaterialInBytes,&expectedMaterialInformation)
 	
-	partInfoAlreadyWritten := false
-	queryString = fmt.Sprintf("{\"selector\":{\"isExpectedMaterialInfoObject\":true,\"ex_PurchaseOrderNumber\":\""+purchaseOrderNumber+"\"}}")
-	expectedPartResultsIterator, expectedPartErr := stub.GetQueryResult(queryString)
+	receipts := getActualMaterialReceipts(stub,purchaseOrderObject.PurchaseOrderNumber,change.MaterialNumber)
+	receivedSoFar := newMaterialReceipts(expectedMaterialInformation,receipts)
+	if len(receipts) > 0 && receivedSoFar.isComplete {
+		return fmt.Errorf("material %s is already delivered completely", change.MaterialNumber)
+	}
 	
-	if expectedPartErr != nil {
-		return Error(http.StatusInternalServerError, expectedPartErr.Error())
+	if change.Quantity != "" {
+		quantity, _ := parsePositiveDecimal(change.Quantity)
+		if quantity.Cmp(receivedSoFar.received) < 0 {
+			return fmt.Errorf("material %s: quantity is below the received quantity %s", change.MaterialNumber, quantityString(receivedSoFar.received))
+		}
+		expectedMaterialInformation.OrderedQuantity = change.Quantity
+	}
+	// the supplier acknowledged the date with the change order
+	if change.RequestedDate != "" {
+		if expectedMaterialInformation.dateStatus() == dateStatusConfirmed && expectedMaterialInformation.ExpectedDate != change.RequestedDate {
+			expectedMaterialInformation.Reschedules = append(expectedMaterialInformation.Reschedules,RescheduleEvent{
+				OldExpectedDate: expectedMaterialInformation.ExpectedDate,
+				NewExpectedDate: change.RequestedDate,
+				RequestedBy: partyBuyer,
+				MspId: changeOrder.MspId,
+				Reason: "Change order "+changeOrder.ChangeOrderNumber+": "+changeOrder.Reason,
+				RescheduledOn: txDate,
+			})
+			// a proposed reschedule does not start from the new date
+			expectedMaterialInformation.PendingReschedule = nil
+		}
+		expectedMaterialInformation.ExpectedDate = change.RequestedDate
+		expectedMaterialInformation.ProposedDate = change.RequestedDate
+		expectedMaterialInformation.DateStatus = dateStatusConfirmed
+	}
+	
+	expectedMaterialInformationObjectInBytes, _ := json.Marshal(expectedMaterialInformation)
+	return stub.PutState("Ex-"+change.MaterialNumber+"-"+purchaseOrderObject.PurchaseOrderNumber, expectedMaterialInformationObjectInBytes)
+}
+
+/*
+ * Function for the supplier to confirm an open purchase order, the supplier is identified by the organization registered
+ * for its supplier code in the invoice chaincode.
+ * 1st - purchase order #
+ */
+func (cc *PurchaseOrder) confirmPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	if party, response := getCallerParty(stub,*purchaseOrderObject); response.Status != http.StatusOK {
+		return response
+	} else if party != partySupplier {
+		return Error(http.StatusForbidden, "Only the supplier of the purchase order may confirm it")
+	}
+	if purchaseOrderObject.status() != purchaseOrderStatusOpen {
+		return Error(http.StatusConflict, "Purchase order is "+purchaseOrderObject.status()+", only open purchase orders can be confirmed")
+	}
+	
+	purchaseOrderObject.Status = purchaseOrderStatusConfirmed
+	if err := putPurchaseOrder(stub,*purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Purchase Order Confirmed Successsfully!", nil)
+}
+
+/*
+ * Function for a buyer organization to change quantities and dates of a purchase order. The change takes effect once the supplier
+ * acknowledged it, until then penalties are calculated on the acknowledged version. One change order may be pending at a time.
+ * 1st - purchase order #, 2nd - changes as json e.g. [{"materialNumber":"M1","quantity":"120","requestedDate":"04/15/2020"}], 3rd - reason
+ */
+func (cc *PurchaseOrder) createChangeOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	
+	if party, response := getCallerParty(stub,*purchaseOrderObject); response.Status != http.StatusOK {
+		return response
+	} else if party != partyBuyer {
+		return Error(http.StatusForbidden, "Only buyer organizations may create change orders")
+	}
+	
+	if !purchaseOrderObject.isActive() || purchaseOrderObject.status() == purchaseOrderStatusReceived {
+		return Error(http.StatusConflict, "Purchase order is "+purchaseOrderObject.status()+" and can not be changed")
+	}
+	if purchaseOrderObject.pendingChangeOrder() != nil {
+		return Error(http.StatusConflict, "Change order "+purchaseOrderObject.pendingChangeOrder().ChangeOrderNumber+" is waiting for acknowledgement")
+	}
+	
+	changes, err := parseChangeOrderChanges(stub,*purchaseOrderObject,args[1])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid changes: "+err.Error())
+	}
+	if purchaseOrderObject.BlanketOrderNumber != "" {
+		if response := checkReleaseChanges(stub,*purchaseOrderObject,changes); response.Status != http.StatusOK {
+			return response
+		}
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	changeOrder := ChangeOrder{
+		ChangeOrderNumber: fmt.Sprintf("%d", len(purchaseOrderObject.ChangeOrders)+1),
+		Changes: changes,
+		Reason: args[2],
+		Status: changeOrderStatusPending,
+		PreviousStatus: purchaseOrderObject.status(),
+		MspId: mspId,
+		RequestedOn: txDate,
+	}
+	purchaseOrderObject.ChangeOrders = append(purchaseOrderObject.ChangeOrders,changeOrder)
+	purchaseOrderObject.Status = purchaseOrderStatusChanged
+	
+	if err := putPurchaseOrder(stub,*purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	changeOrderInBytes, _ := json.Marshal(changeOrder)
+	return Success(http.StatusCreated,"Change Order Created Successsfully!", changeOrderInBytes)
+}
+
+/*
+ * Function for the supplier to acknowledge or reject the pending change order of a purchase order. An acknowledged
+ * change order is applied to the purchase order lines and the expected material information.
+ * 1st - purchase order #, 2nd - change order #, 3rd - true to acknowledge, false to reject
+ * optional: 4th - comment, mandatory to reject
+ */
+func (cc *PurchaseOrder) acknowledgeChangeOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 3 || len(args) > 4 || (args[2] != "true" && args[2] != "false") {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	isAcknowledged := args[2] == "true"
+	comment := ""
+	if len(args) > 3 {
+		comment = args[3]
+	}
+	if !isAcknowledged && comment == "" {
+		return Error(http.StatusNotAcceptable, "Comment is mandatory to reject a change order")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	
+	if party, response := getCallerParty(stub,*purchaseOrderObject); response.Status != http.StatusOK {
+		return response
+	} else if party != partySupplier {
+		return Error(http.StatusForbidden, "Only the supplier of the purchase order may acknowledge change orders")
+	}
+	
+	changeOrder := purchaseOrderObject.pendingChangeOrder()
+	if changeOrder == nil || changeOrder.ChangeOrderNumber != args[1] {
+		return Error(http.StatusNotFound, "No pending change order "+args[1]+" for purchase order "+args[0])
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	changeOrder.Status = changeOrderStatusRejected
+	if isAcknowledged {
+		// other releases may have drawn down the blanket purchase order since the change order was created
+		if purchaseOrderObject.BlanketOrderNumber != "" {
+			if response := checkReleaseChanges(stub,*purchaseOrderObject,changeOrder.Changes); response.Status != http.StatusOK {
+				return response
+			}
+		}
+		
+		for _, change := range changeOrder.Changes {
+			if err := applyChange(stub,purchaseOrderObject,*changeOrder,change,txDate); err != nil {
+				return Error(http.StatusConflict, "Change order can not be applied: "+err.Error())
+			}
+		}
+		
+		changeOrder.Status = changeOrderStatusAcknowledged
+		if purchaseOrderObject.Version == 0 {
+			purchaseOrderObject.Version = 1
+		}
+		purchaseOrderObject.Version++
+	}
+	changeOrder.DecidedOn = txDate
+	changeOrder.Comment = comment
+	
+	// an acknowledged change confirms an open purchase order
+	purchaseOrderObject.Status = changeOrder.PreviousStatus
+	if isAcknowledged && purchaseOrderObject.Status == purchaseOrderStatusOpen {
+		purchaseOrderObject.Status = purchaseOrderStatusConfirmed
+	}
+	
+	if err := putPurchaseOrder(stub,*purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	if !isAcknowledged {
+		return Success(http.StatusOK,"Change Order Rejected Successsfully!", nil)
+	}
+	return Success(http.StatusOK,"Change Order Acknowledged Successsfully!", nil)
+}
+
+/*
+ * Function to cancel a purchase order nothing was received on yet.
+ * 1st - purchase order #, 2nd - reason
+ */
+func (cc *PurchaseOrder) cancelPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 || args[1] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	
+	status := purchaseOrderObject.status()
+	if changeOrder := purchaseOrderObject.pendingChangeOrder(); changeOrder != nil {
+		status = changeOrder.PreviousStatus
+	}
+	if status != purchaseOrderStatusOpen && status != purchaseOrderStatusConfirmed {
+		return Error(http.StatusConflict, "Purchase order is "+purchaseOrderObject.status()+", only purchase orders nothing was received on can be cancelled")
+	}
+	
+	// a pending change order is void with the purchase order
+	if changeOrder := purchaseOrderObject.pendingChangeOrder(); changeOrder != nil {
+		changeOrder.Status = changeOrderStatusRejected
+		changeOrder.Comment = "Purchase order cancelled"
+	}
+	purchaseOrderObject.Status = purchaseOrderStatusCancelled
+	purchaseOrderObject.StatusReason = args[1]
+	
+	if err := putPurchaseOrder(stub,*purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Purchase Order Cancelled Successsfully!", nil)
+}
+
+/*
+ * Function to close a purchase order once it is received, or to close a partially received purchase order short.
+ * 1st - purchase order #
+ * optional: 2nd - reason
+ */
+func (cc *PurchaseOrder) closePurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	if status := purchaseOrderObject.status(); status != purchaseOrderStatusReceived && status != purchaseOrderStatusPartiallyReceived {
+		return Error(http.StatusConflict, "Purchase order is "+status+", only received purchase orders can be closed")
+	}
+	
+	purchaseOrderObject.Status = purchaseOrderStatusClosed
+	if len(args) > 1 {
+		purchaseOrderObject.StatusReason = args[1]
+	}
+	
+	if err := putPurchaseOrder(stub,*purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Purchase Order Closed Successsfully!", nil)
+}
+
+/*
+ * Read blanket purchase order from blockchain, returns nil blanket purchase order when it does not exist.
+ */
+func getBlanketPurchaseOrderById(stub shim.ChaincodeStubInterface, blanketOrderNumber string) (*BlanketPurchaseOrder, error) {
+	blanketOrderInBytes, err := stub.GetState("BPO-"+blanketOrderNumber)
+	if err != nil || blanketOrderInBytes == nil {
+		return nil, err
+	}
+	
+	var blanketOrderObject BlanketPurchaseOrder
+	if err := json.Unmarshal(blanketOrderInBytes,&blanketOrderObject); err != nil {
+		return nil, err
+	}
+	return &blanketOrderObject, nil
+}
+
+/*
+ * Write blanket purchase order to blockchain.
+ */
+func putBlanketPurchaseOrder(stub shim.ChaincodeStubInterface, blanketOrderObject BlanketPurchaseOrder) error {
+	blanketOrderObject.IsBlanketPurchaseOrderObject = true
+	
+	// convert to byte
+	blanketOrderObjectInBytes, _ := json.Marshal(blanketOrderObject)
+	
+	// write blanket purchase order to BC
+	return stub.PutState("BPO-"+blanketOrderObject.BlanketOrderNumber, blanketOrderObjectInBytes)
+}
+
+/*
+ * Get the agreed line of a material, nil when the material is not agreed on the blanket purchase order.
+ */
+func (blanketOrderObject BlanketPurchaseOrder) lineForMaterial(materialNumber string) *PurchaseOrderLine {
+	for i := range blanketOrderObject.Lines {
+		if blanketOrderObject.Lines[i].MaterialNumber == materialNumber {
+			return &blanketOrderObject.Lines[i]
+		}
+	}
+	return nil
+}
+
+/*
+ * Check if a date is within the validity period of the blanket purchase order.
+ */
+func (blanketOrderObject BlanketPurchaseOrder) isValidOn(date string) bool {
+	validOn, err := time.Parse(timeFormat,date)
+	if err != nil {
+		return false
+	}
+	validFrom, _ := time.Parse(timeFormat,blanketOrderObject.ValidFrom)
+	validTo, _ := time.Parse(timeFormat,blanketOrderObject.ValidTo)
+	return !validOn.Before(validFrom) && !validOn.After(validTo)
+}
+
+/*
+ * Validate the lines of a release against the blanket purchase order. Unit of measure and unit price default to the
+ * agreed line and must not differ from it, requested dates must be within the validity period.
+ */
+func (blanketOrderObject BlanketPurchaseOrder) validateReleaseLines(lines []PurchaseOrderLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("at least one line is mandatory")
+	}
+	
+	for i := range lines {
+		line := &lines[i]
+		agreedLine := blanketOrderObject.lineForMaterial(line.MaterialNumber)
+		if agreedLine == nil {
+			return fmt.Errorf("line %s: material %s is not agreed on blanket purchase order %s", line.LineNumber, line.MaterialNumber, blanketOrderObject.BlanketOrderNumber)
+		}
+		
+		if line.UnitOfMeasure == "" {
+			line.UnitOfMeasure = agreedLine.UnitOfMeasure
+		} else if line.UnitOfMeasure != agreedLine.UnitOfMeasure {
+			return fmt.Errorf("line %s: unitOfMeasure differs from the agreed unit of measure %s", line.LineNumber, agreedLine.UnitOfMeasure)
+		}
+		
+		if line.UnitPrice == "" {
+			line.UnitPrice = agreedLine.UnitPrice
+		} else if !isSameDecimal(line.UnitPrice,agreedLine.UnitPrice) {
+			return fmt.Errorf("line %s: unitPrice differs from the agreed unit price %s", line.LineNumber, agreedLine.UnitPrice)
+		}
+		
+		if line.RequestedDate != "" && !blanketOrderObject.isValidOn(line.RequestedDate) {
+			return fmt.Errorf("line %s: requestedDate is not within the validity period %s - %s", line.LineNumber, blanketOrderObject.ValidFrom, blanketOrderObject.ValidTo)
+		}
+	}
+	
+	return nil
+}
+
+// quantities per material and value drawn down from a blanket purchase order by its releases
+type blanketReleases struct {
+	releases []BlanketOrderRelease
+	released map[string]*big.Rat
+	releasedValue *big.Rat
+}
+
+/*
+ * Sum up the lines of the releases of a blanket purchase order, cancelled releases do not draw down the blanket
+ * purchase order. The excluded release is left out, e.g. to check changes of its own lines.
+ */
+func getBlanketReleases(stub shim.ChaincodeStubInterface, blanketOrderObject BlanketPurchaseOrder, excludedPurchaseOrderNumber string) (blanketReleases blanketReleases, err error) {
+	blanketReleases.releases = []BlanketOrderRelease{}
+	blanketReleases.released = map[string]*big.Rat{}
+	blanketReleases.releasedValue = new(big.Rat)
+	
+	for _, purchaseOrderNumber := range blanketOrderObject.Releases {
+		purchaseOrderObject, err := getPurchaseOrderById(stub,purchaseOrderNumber)
+		if err != nil {
+			return blanketReleases, err
+		}
+		if purchaseOrderObject == nil {
+			continue
+		}
+		
+		blanketReleases.releases = append(blanketReleases.releases,BlanketOrderRelease{
+			PurchaseOrderNumber: purchaseOrderNumber,
+			PurchaseOrderStatus: purchaseOrderObject.status(),
+		})
+		if purchaseOrderNumber == excludedPurchaseOrderNumber || purchaseOrderObject.status() == purchaseOrderStatusCancelled {
+			continue
+		}
+		blanketReleases.add(purchaseOrderObject.Lines)
+	}
+	return
+}
+
+/*
+ * Add the lines of a release to the released quantities and value.
+ */
+func (blanketReleases *blanketReleases) add(lines []PurchaseOrderLine) {
+	for _, line := range lines {
+		quantity, ok := parsePositiveDecimal(line.Quantity)
+		if !ok {
+			continue
+		}
+		if blanketReleases.released[line.MaterialNumber] == nil {
+			blanketReleases.released[line.MaterialNumber] = new(big.Rat)
+		}
+		blanketReleases.released[line.MaterialNumber].Add(blanketReleases.released[line.MaterialNumber],quantity)
+		
+		if unitPrice, ok := parsePositiveDecimal(line.UnitPrice); ok {
+			blanketReleases.releasedValue.Add(blanketReleases.releasedValue,new(big.Rat).Mul(quantity,unitPrice))
+		}
+	}
+}
+
+/*
+ * Get the quantity of a material which is not released yet.
+ */
+func (blanketReleases blanketReleases) remainingQuantity(agreedLine PurchaseOrderLine) *big.Rat {
+	remaining, ok := parsePositiveDecimal(agreedLine.Quantity)
+	if !ok {
+		return new(big.Rat)
+	}
+	if released := blanketReleases.released[agreedLine.MaterialNumber]; released != nil {
+		remaining.Sub(remaining,released)
+	}
+	return remaining
+}
+
+/*
+ * Get the value which is not released yet, nil when no total value is agreed.
+ */
+func (blanketReleases blanketReleases) remainingValue(blanketOrderObject BlanketPurchaseOrder) *big.Rat {
+	totalValue, ok := parsePositiveDecimal(blanketOrderObject.TotalValue)
+	if !ok {
+		return nil
+	}
+	return totalValue.Sub(totalValue,blanketReleases.releasedValue)
+}
+
+/*
+ * Check that the lines of a release stay within the remaining quantities and the remaining value of the blanket
+ * purchase order.
+ */
+func (blanketReleases blanketReleases) checkBalance(blanketOrderObject BlanketPurchaseOrder, lines []PurchaseOrderLine) error {
+	releaseValue := new(big.Rat)
+	for _, line := range lines {
+		quantity, _ := parsePositiveDecimal(line.Quantity)
+		remaining := blanketReleases.remainingQuantity(*blanketOrderObject.lineForMaterial(line.MaterialNumber))
+		if quantity.Cmp(remaining) > 0 {
+			return fmt.Errorf("material %s exceeds the remaining quantity %s of blanket purchase order %s", line.MaterialNumber, quantityString(remaining), blanketOrderObject.BlanketOrderNumber)
+		}
+		
+		unitPrice, _ := parsePositiveDecimal(line.UnitPrice)
+		releaseValue.Add(releaseValue,new(big.Rat).Mul(quantity,unitPrice))
+	}
+	
+	if remaining := blanketReleases.remainingValue(blanketOrderObject); remaining != nil && releaseValue.Cmp(remaining) > 0 {
+		return fmt.Errorf("release value %s exceeds the remaining value %s of blanket purchase order %s", quantityString(releaseValue), quantityString(remaining), blanketOrderObject.BlanketOrderNumber)
+	}
+	return nil
+}
+
+/*
+ * Check the changes of a change order of a release against its blanket purchase order, changed quantities must stay
+ * within the remaining balance and changed requested dates within the validity period.
+ */
+func checkReleaseChanges(stub shim.ChaincodeStubInterface, purchaseOrderObject PurchaseOrder, changes []PurchaseOrderLine) peer.Response {
+	blanketOrderObject, err := getBlanketPurchaseOrderById(stub,purchaseOrderObject.BlanketOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if blanketOrderObject == nil {
+		return Error(http.StatusNotFound, "Blanket purchase order "+purchaseOrderObject.BlanketOrderNumber+" not found")
+	}
+	
+	// lines of the release as they are once the changes are applied
+	changedRelease := PurchaseOrder{Lines: make([]PurchaseOrderLine, len(purchaseOrderObject.Lines))}
+	copy(changedRelease.Lines,purchaseOrderObject.Lines)
+	for _, change := range changes {
+		if change.RequestedDate != "" && !blanketOrderObject.isValidOn(change.RequestedDate) {
+			return Error(http.StatusNotAcceptable, "Invalid changes: material "+change.MaterialNumber+": requestedDate is not within the validity period "+blanketOrderObject.ValidFrom+" - "+blanketOrderObject.ValidTo)
+		}
+		if line := changedRelease.lineForMaterial(change.MaterialNumber); line != nil && change.Quantity != "" {
+			line.Quantity = change.Quantity
+		}
+	}
+	
+	blanketReleases, err := getBlanketReleases(stub,*blanketOrderObject,purchaseOrderObject.PurchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := blanketReleases.checkBalance(*blanketOrderObject,changedRelease.Lines); err != nil {
+		return Error(http.StatusConflict, "Change order exceeds the blanket purchase order: "+err.Error())
+	}
+	
+	return Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Function for a buyer organization to create a blanket purchase order, a framework agreement with a supplier which is
+ * called off with releases.
+ * 1st - blanket purchase order #, 2nd - supplier code, 3rd - supplier location, 4th - valid from, 5th - valid to,
+ * 6th - agreed lines as json e.g. [{"materialNumber":"M1","quantity":"1200","unitOfMeasure":"PC","unitPrice":"12.50"}]
+ * optional: 7th - total value the releases must not exceed
+ */
+func (cc *PurchaseOrder) createBlanketPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 6 || len(args) > 7 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	if response := checkCallerIsBuyer(stub,"create blanket purchase orders"); response.Status != http.StatusOK {
+		return response
+	}
+	
+	validFrom, err := time.Parse(timeFormat,args[3])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Valid from must be in format "+timeFormat)
+	}
+	validTo, err := time.Parse(timeFormat,args[4])
+	if err != nil || validTo.Before(validFrom) {
+		return Error(http.StatusNotAcceptable, "Valid to must be in format "+timeFormat+" and not before valid from")
+	}
+	
+	lines, err := parsePurchaseOrderLines(args[5])
+	if err == nil && len(lines) == 0 {
+		err = fmt.Errorf("at least one line is mandatory")
+	}
+	for i := 0; err == nil && i < len(lines); i++ {
+		if lines[i].UnitPrice == "" {
+			err = fmt.Errorf("line %s: unitPrice is mandatory", lines[i].LineNumber)
+		}
+	}
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid blanket purchase order lines: "+err.Error())
+	}
+	
+	totalValue := ""
+	if len(args) > 6 && args[6] != "" {
+		totalValue = args[6]
+		if _, ok := parsePositiveDecimal(totalValue); !ok {
+			return Error(http.StatusNotAcceptable, "Total value must be a decimal number greater than 0")
+		}
+	}
+	
+	// Check if blanket purchase order already exists
+	if validateValue, validateErr := stub.GetState("BPO-"+args[0]); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Blanket purchase order already exists")
+	}
+	
+	blanketOrderObject := BlanketPurchaseOrder{
+		BlanketOrderNumber: args[0],
+		SupplierCode: args[1],
+		SupplierLocation: args[2],
+		ValidFrom: args[3],
+		ValidTo: args[4],
+		TotalValue: totalValue,
+		Lines: lines,
+		Releases: []string{},
+	}
+	
+	if err := putBlanketPurchaseOrder(stub,blanketOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusCreated,"Blanket Purchase Order Created Successsfully!", nil)
+}
+
+/*
+ * Function for a buyer organization to release a purchase order from a blanket purchase order within its validity
+ * period. The release is a purchase order of the blanket purchase order's supplier, expected and actual material
+ * information and penalties work on it as on any other purchase order. Cancelled releases give their quantities back to the blanket purchase order.
+ * 1st - blanket purchase order #, 2nd - purchase order # of the release,
+ * 3rd - lines as json e.g. [{"materialNumber":"M1","quantity":"100","requestedDate":"03/31/2020"}]
+ */
+func (cc *PurchaseOrder) createReleaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 || args[1] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	if response := checkCallerIsBuyer(stub,"release purchase orders"); response.Status != http.StatusOK {
+		return response
+	}
+	
+	lines, err := parsePurchaseOrderLines(args[2])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid release lines: "+err.Error())
+	}
+	
+	blanketOrderObject, err := getBlanketPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if blanketOrderObject == nil {
+		return Error(http.StatusNotFound, "Blanket purchase order "+args[0]+" not found")
+	}
+	
+	// Check if purchase order already exists
+	if validateValue, validateErr := stub.GetState(args[1]); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Purchase order already exists")
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if !blanketOrderObject.isValidOn(txDate) {
+		return Error(http.StatusConflict, "Blanket purchase order "+args[0]+" is valid from "+blanketOrderObject.ValidFrom+" to "+blanketOrderObject.ValidTo)
+	}
+	
+	if err := blanketOrderObject.validateReleaseLines(lines); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid release lines: "+err.Error())
+	}
+	
+	blanketReleases, err := getBlanketReleases(stub,*blanketOrderObject,"")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := blanketReleases.checkBalance(*blanketOrderObject,lines); err != nil {
+		return Error(http.StatusConflict, "Release exceeds the blanket purchase order: "+err.Error())
+	}
+	
+	// the release is open until the supplier confirms it like any other purchase order
+	purchaseOrderObject := PurchaseOrder{
+		PurchaseOrderNumber: args[1],
+		SupplierCode: blanketOrderObject.SupplierCode,
+		SupplierLocation: blanketOrderObject.SupplierLocation,
+		Lines: lines,
+		Status: purchaseOrderStatusOpen,
+		Version: 1,
+		ChangeOrders: []ChangeOrder{},
+		BlanketOrderNumber: args[0],
+	}
+	if err := putPurchaseOrder(stub,purchaseOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	blanketOrderObject.Releases = append(blanketOrderObject.Releases,args[1])
+	if err := putBlanketPurchaseOrder(stub,*blanketOrderObject); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusCreated,"Release Order Created Successsfully!", nil)
+}
+
+/*
+ * Function to get a blanket purchase order with its releases and the quantities and value which remain to be released.
+ * 1st - blanket purchase order #
+ */
+func (cc *PurchaseOrder) getBlanketPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	blanketOrderObject, err := getBlanketPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if blanketOrderObject == nil {
+		return Error(http.StatusNotFound, "Blanket purchase order "+args[0]+" not found")
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	blanketReleases, err := getBlanketReleases(stub,*blanketOrderObject,"")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	blanketOrderBalance := BlanketOrderBalance{
+		BlanketOrderNumber: blanketOrderObject.BlanketOrderNumber,
+		SupplierCode: blanketOrderObject.SupplierCode,
+		SupplierLocation: blanketOrderObject.SupplierLocation,
+		ValidFrom: blanketOrderObject.ValidFrom,
+		ValidTo: blanketOrderObject.ValidTo,
+		IsValid: blanketOrderObject.isValidOn(txDate),
+		TotalValue: blanketOrderObject.TotalValue,
+		ReleasedValue: quantityString(blanketReleases.releasedValue),
+		Lines: []BlanketOrderLineBalance{},
+		Releases: blanketReleases.releases,
+	}
+	if remaining := blanketReleases.remainingValue(*blanketOrderObject); remaining != nil {
+		blanketOrderBalance.RemainingValue = quantityString(remaining)
+	}
+	
+	for _, line := range blanketOrderObject.Lines {
+		released := new(big.Rat)
+		if blanketReleases.released[line.MaterialNumber] != nil {
+			released = blanketReleases.released[line.MaterialNumber]
+		}
+		blanketOrderBalance.Lines = append(blanketOrderBalance.Lines,BlanketOrderLineBalance{
+			LineNumber: line.LineNumber,
+			MaterialNumber: line.MaterialNumber,
+			UnitOfMeasure: line.UnitOfMeasure,
+			UnitPrice: line.UnitPrice,
+			Quantity: line.Quantity,
+			ReleasedQuantity: quantityString(released),
+			RemainingQuantity: quantityString(blanketReleases.remainingQuantity(line)),
+		})
+	}
+	
+	blanketOrderBalanceInBytes, _ := json.Marshal(blanketOrderBalance)
+	return Success(http.StatusOK, "OK", blanketOrderBalanceInBytes)
+}
+
+/*
+ * Function to create the expected delivery date of a material of a purchase order.
+ * 1st - material number, 2nd - purchase order #, 3rd - expected date, defaults to the requested date of the purchase order line
+ * optional: 4th - ordered quantity, 5th - unit price, penalties of materials with ordered quantity are pro-rated on the
+ * quantity delivered late, both default to the purchase order line and must not differ from it
+ */
+func (cc *PurchaseOrder) createExpectedMaterialInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	if len(args) < 3 || len(args) > 5 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	orderedQuantity := ""
+	if len(args) > 3 && args[3] != "" {
+		orderedQuantity = args[3]
+		if _, ok := parsePositiveDecimal(orderedQuantity); !ok {
+			return Error(http.StatusNotAcceptable, "Ordered quantity must be a decimal number greater than 0")
+		}
+	}
+	
+	unitPrice := ""
+	if len(args) > 4 && args[4] != "" {
+		unitPrice = args[4]
+		if _, ok := parsePositiveDecimal(unitPrice); !ok {
+			return Error(http.StatusNotAcceptable, "Unit price must be a decimal number greater than 0")
+		}
+	}
+	
+	// Check if material expected date info already exists
+	if validateValue, validateErr := stub.GetState("Ex-"+args[0]+"-"+args[1]); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Expected Date for purchase order "+args[1]+" and material number "+args[0]+" already exists!")
+	}
+	
+	// materials of a purchase order with lines are validated against the line they are ordered on
+	expectedDate := args[2]
+	var purchaseOrderObject PurchaseOrder
+	purchaseOrderInBytes, _ := stub.GetState(args[1])
+	json.Unmarshal(purchaseOrderInBytes,&purchaseOrderObject)
+	if !purchaseOrderObject.isActive() {
+		return Error(http.StatusConflict, "Purchase order "+args[1]+" is "+purchaseOrderObject.status())
+	}
+	if len(purchaseOrderObject.Lines) > 0 {
+		line := purchaseOrderObject.lineForMaterial(args[0])
+		if line == nil {
+			return Error(http.StatusNotFound, "Material number "+args[0]+" is not ordered on purchase order "+args[1])
+		}
+		
+		if expectedDate == "" {
+			expectedDate = line.RequestedDate
+		}
+		if orderedQuantity == "" {
+			orderedQuantity = line.Quantity
+		} else if !isSameDecimal(orderedQuantity,line.Quantity) {
+			return Error(http.StatusNotAcceptable, "Ordered quantity differs from quantity "+line.Quantity+" of purchase order line "+line.LineNumber)
+		}
+		if unitPrice == "" {
+			unitPrice = line.UnitPrice
+		} else if line.UnitPrice != "" && !isSameDecimal(unitPrice,line.UnitPrice) {
+			return Error(http.StatusNotAcceptable, "Unit price differs from unit price "+line.UnitPrice+" of purchase order line "+line.LineNumber)
+		}
+	}
+	
+	if expectedDate == "" {
+		return Error(http.StatusNotAcceptable, "Expected date is mandatory")
+	}
+	
+	// the buyer proposes the expected date, delays are calculated once the supplier confirmed it
+	expectedMaterialInformationObject := &ExpectedMaterialInformation{
+		MaterialNumber: args[0],
+		Ex_PurchaseOrderNumber: args[1],
+		ProposedDate: expectedDate,
+		ProposedBy: partyBuyer,
+		DateStatus: dateStatusProposed,
+		OrderedQuantity: orderedQuantity,
+		UnitPrice: unitPrice,
+		IsExpectedMaterialInfoObject: true,
+	}	
+
+	// convert to byte
+	expectedMaterialInformationObjectInBytes, _ := json.Marshal(expectedMaterialInformationObject)
+
+	// write material info to BC
+	if err := stub.PutState("Ex-"+args[0]+"-"+args[1], expectedMaterialInformationObjectInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusCreated,"Material's expected delivery date information created successsfully!", nil)
+}
+
+/*
+ * Get the state of the expected date of a material, dates created before suppliers confirmed them count as confirmed.
+ */
+func (expectedMaterialInformation ExpectedMaterialInformation) dateStatus() string {
+	if expectedMaterialInformation.DateStatus == "" {
+		return dateStatusConfirmed
+	}
+	return expectedMaterialInformation.DateStatus
+}
+
+/*
+ * Determine whether the client which submitted the transaction acts as buyer or as supplier of the purchase order.
+ * Buyer organizations are configured in the chaincode config, organizations of suppliers are registered in the invoice
+ * chaincode. Clients of other organizations are not allowed to act on the purchase order.
+ */
+func getCallerParty(stub shim.ChaincodeStubInterface, purchaseOrderObject PurchaseOrder) (string, peer.Response) {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return "", Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	callerMspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	for _, mspId := range config.BuyerMspIds {
+		if mspId == callerMspId {
+			return partyBuyer, Success(http.StatusOK, "OK", nil)
+		}
+	}
+	
+	supplierResponse := invokeInvoiceChaincode(stub,"getSupplier",purchaseOrderObject.SupplierCode)
+	if supplierResponse.Status != http.StatusOK && supplierResponse.Status != http.StatusNotFound {
+		return "", Error(http.StatusInternalServerError, "Supplier could not be read: "+supplierResponse.Message)
+	}
+	if supplierResponse.Status == http.StatusOK {
+		var supplier Supplier
+		json.Unmarshal(supplierResponse.Payload,&supplier)
+		if supplier.MspId == callerMspId {
+			return partySupplier, Success(http.StatusOK, "OK", nil)
+		}
+	}
+	
+	return "", Error(http.StatusForbidden, "Organization "+callerMspId+" is neither a buyer organization nor the supplier of purchase order "+purchaseOrderObject.PurchaseOrderNumber)
+}
+
+/*
+ * Check that the client which submitted the transaction belongs to one of the buyer organizations of the chaincode config.
+ */
+func checkCallerIsBuyer(stub shim.ChaincodeStubInterface, action string) peer.Response {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	callerMspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	for _, mspId := range config.BuyerMspIds {
+		if mspId == callerMspId {
+			return Success(http.StatusOK, "OK", nil)
+		}
+	}
+	return Error(http.StatusForbidden, "Not allowed to "+action+": organization "+callerMspId+" is no buyer organization")
+}
+
+/*
+ * Read the expected material information of a material and the purchase order it is expected on, and determine whether
+ * the caller acts as buyer or as supplier of the purchase order.
+ */
+func getExpectedMaterialWithParty(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) (*ExpectedMaterialInformation, string, peer.Response) {
+	expectedMaterialInBytes, err := stub.GetState("Ex-"+materialNumber+"-"+purchaseOrderNumber)
+	if err != nil {
+		return nil, "", Error(http.StatusInternalServerError, err.Error())
+	}
+	if expectedMaterialInBytes == nil {
+		return nil, "", Error(http.StatusNotFound, "Material number "+materialNumber+" of purchase order "+purchaseOrderNumber+" not found")
+	}
+	
+	var expectedMaterialInformation ExpectedMaterialInformation
+	json.Unmarshal(expectedMaterialInBytes,&expectedMaterialInformation)
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,purchaseOrderNumber)
+	if err != nil {
+		return nil, "", Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return nil, "", Error(http.StatusNotFound, "Purchase order "+purchaseOrderNumber+" not found")
+	}
+	if !purchaseOrderObject.isActive() {
+		return nil, "", Error(http.StatusConflict, "Purchase order "+purchaseOrderNumber+" is "+purchaseOrderObject.status())
+	}
+	
+	party, response := getCallerParty(stub,*purchaseOrderObject)
+	if response.Status != http.StatusOK {
+		return nil, "", response
+	}
+	return &expectedMaterialInformation, party, response
+}
+
+/*
+ * Write expected material information to blockchain.
+ */
+func putExpectedMaterialInformation(stub shim.ChaincodeStubInterface, expectedMaterialInformation ExpectedMaterialInformation) error {
+	expectedMaterialInformation.IsExpectedMaterialInfoObject = true
+	
+	// convert to byte
+	expectedMaterialInformationObjectInBytes, _ := json.Marshal(expectedMaterialInformation)
+	
+	// write material info to BC
+	return stub.PutState("Ex-"+expectedMaterialInformation.MaterialNumber+"-"+expectedMaterialInformation.Ex_PurchaseOrderNumber, expectedMaterialInformationObjectInBytes)
+}
+
+/*
+ * Function for the buyer to propose another expected date for a material, e.g. in reply to a counter proposal of the
+ * supplier. Confirmed dates are changed with change orders.
+ * 1st - material number, 2nd - purchase order #, 3rd - proposed date
+ */
+func (cc *PurchaseOrder) proposeExpectedDate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	if _, err := time.Parse(timeFormat,args[2]); err != nil {
+		return Error(http.StatusNotAcceptable, "Proposed date must be in format "+timeFormat)
+	}
+	
+	expectedMaterialInformation, party, response := getExpectedMaterialWithParty(stub,args[0],args[1])
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if party != partyBuyer {
+		return Error(http.StatusForbidden, "The supplier counter-proposes expected dates instead")
+	}
+	if expectedMaterialInformation.dateStatus() == dateStatusConfirmed {
+		return Error(http.StatusConflict, "Expected date is confirmed already, it can only be changed with a change order")
+	}
+	
+	expectedMaterialInformation.ProposedDate = args[2]
+	expectedMaterialInformation.ProposedBy = partyBuyer
+	expectedMaterialInformation.DateStatus = dateStatusProposed
+	expectedMaterialInformation.ProposalReason = ""
+	
+	if err := putExpectedMaterialInformation(stub,*expectedMaterialInformation); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Expected Date Proposed Successsfully!", nil)
+}
+
+/*
+ * Function to confirm the proposed expected date of a material, the supplier confirms dates proposed by the buyer and
+ * the buyer confirms dates counter-proposed by the supplier. Only confirmed dates are used to calculate delays.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *PurchaseOrder) confirmExpectedDate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	expectedMaterialInformation, party, response := getExpectedMaterialWithParty(stub,args[0],args[1])
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if expectedMaterialInformation.dateStatus() == dateStatusConfirmed {
+		return Error(http.StatusConflict, "Expected date is confirmed already")
+	}
+	if party == expectedMaterialInformation.ProposedBy {
+		return Error(http.StatusForbidden, "The expected date has to be confirmed by the other party than the one which proposed it")
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	expectedMaterialInformation.ExpectedDate = expectedMaterialInformation.ProposedDate
+	expectedMaterialInformation.DateStatus = dateStatusConfirmed
+	expectedMaterialInformation.ConfirmedOn = txDate
+	
+	if err := putExpectedMaterialInformation(stub,*expectedMaterialInformation); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Expected Date Confirmed Successsfully!", nil)
+}
+
+/*
+ * Function for the supplier to counter-propose the expected date the buyer proposed for a material.
+ * 1st - material number, 2nd - purchase order #, 3rd - counter-proposed date, 4th - reason
+ */
+func (cc *PurchaseOrder) counterProposeExpectedDate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 || args[3] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	if _, err := time.Parse(timeFormat,args[2]); err != nil {
+		return Error(http.StatusNotAcceptable, "Counter-proposed date must be in format "+timeFormat)
+	}
+	
+	expectedMaterialInformation, party, response := getExpectedMaterialWithParty(stub,args[0],args[1])
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if party != partySupplier {
+		return Error(http.StatusForbidden, "Only the supplier of the purchase order may counter-propose expected dates")
+	}
+	if expectedMaterialInformation.dateStatus() != dateStatusProposed {
+		return Error(http.StatusConflict, "Expected date is "+expectedMaterialInformation.dateStatus()+", only dates proposed by the buyer can be counter-proposed")
+	}
+	
+	expectedMaterialInformation.ProposedDate = args[2]
+	expectedMaterialInformation.ProposedBy = partySupplier
+	expectedMaterialInformation.DateStatus = dateStatusCounterProposed
+	expectedMaterialInformation.ProposalReason = args[3]
+	
+	if err := putExpectedMaterialInformation(stub,*expectedMaterialInformation); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Expected Date Counter-Proposed Successsfully!", nil)
+}
+
+/*
+ * Function for the buyer or the supplier to propose a reschedule of the confirmed expected date of a material which is
+ * not completely delivered yet. The expected date is kept until the other party confirmed the reschedule, a new proposal
+ * of either party replaces a pending one.
+ * 1st - material number, 2nd - purchase order #, 3rd - new expected date, 4th - reason
+ */
+func (cc *PurchaseOrder) rescheduleExpectedDate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 || args[3] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	if _, err := time.Parse(timeFormat,args[2]); err != nil {
+		return Error(http.StatusNotAcceptable, "New expected date must be in format "+timeFormat)
+	}
+	
+	expectedMaterialInformation, party, response := getExpectedMaterialWithParty(stub,args[0],args[1])
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if expectedMaterialInformation.dateStatus() != dateStatusConfirmed {
+		return Error(http.StatusConflict, "Expected date is "+expectedMaterialInformation.dateStatus()+", only confirmed dates can be rescheduled")
+	}
+	if expectedMaterialInformation.ExpectedDate == args[2] {
+		return Error(http.StatusNotAcceptable, "New expected date must differ from the expected date "+expectedMaterialInformation.ExpectedDate)
+	}
+	
+	materialReceipts := newMaterialReceipts(*expectedMaterialInformation,getActualMaterialReceipts(stub,args[1],args[0]))
+	if materialReceipts.isComplete {
+		return Error(http.StatusConflict, "Material number "+args[0]+" of purchase order "+args[1]+" is delivered already")
+	}
+	
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	expectedMaterialInformation.PendingReschedule = &RescheduleEvent{
+		OldExpectedDate: expectedMaterialInformation.ExpectedDate,
+		NewExpectedDate: args[2],
+		RequestedBy: party,
+		MspId: mspId,
+		Reason: args[3],
+	}
+	
+	if err := putExpectedMaterialInformation(stub,*expectedMaterialInformation); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Expected Date Reschedule Proposed Successsfully!", nil)
+}
+
+/*
+ * Function for the other party than the one which proposed it to confirm the pending reschedule of the expected date of
+ * a material. The old and new date are kept with the party which requested the reschedule and the reason.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *PurchaseOrder) confirmReschedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	expectedMaterialInformation, party, response := getExpectedMaterialWithParty(stub,args[0],args[1])
+	if response.Status != http.StatusOK {
+		return response
+	}
+	
+	reschedule := expectedMaterialInformation.PendingReschedule
+	if reschedule == nil {
+		return Error(http.StatusNotFound, "No pending reschedule for material number "+args[0]+" of purchase order "+args[1])
+	}
+	if party == reschedule.RequestedBy {
+		return Error(http.StatusForbidden, "The reschedule has to be confirmed by the other party than the one which proposed it")
+	}
+	// a change order may have moved the expected date since the reschedule was proposed
+	if expectedMaterialInformation.ExpectedDate != reschedule.OldExpectedDate {
+		return Error(http.StatusConflict, "Expected date changed to "+expectedMaterialInformation.ExpectedDate+" since the reschedule was proposed")
+	}
+	
+	materialReceipts := newMaterialReceipts(*expectedMaterialInformation,getActualMaterialReceipts(stub,args[1],args[0]))
+	if materialReceipts.isComplete {
+		return Error(http.StatusConflict, "Material number "+args[0]+" of purchase order "+args[1]+" is delivered already")
+	}
+	
+	txDate, err := getTxDate(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	reschedule.RescheduledOn = txDate
+	expectedMaterialInformation.Reschedules = append(expectedMaterialInformation.Reschedules,*reschedule)
+	expectedMaterialInformation.ExpectedDate = reschedule.NewExpectedDate
+	expectedMaterialInformation.ProposedDate = reschedule.NewExpectedDate
+	expectedMaterialInformation.ProposedBy = reschedule.RequestedBy
+	expectedMaterialInformation.ProposalReason = reschedule.Reason
+	expectedMaterialInformation.PendingReschedule = nil
+	
+	if err := putExpectedMaterialInformation(stub,*expectedMaterialInformation); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	return Success(http.StatusOK,"Expected Date Rescheduled Successsfully!", nil)
+}
+
+/*
+ * Function to record a goods receipt of a material of a purchase order.
+ * 1st - material number, 2nd - purchase order #, 3rd - actual date, 4th - delay reason
+ * optional: 5th - received quantity, 6th - receipt #, a material with ordered quantity may be received in several
+ * receipts, a receipt without quantity delivers the remaining quantity
+ */
+func (cc *PurchaseOrder) createActualMaterialInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	if len(args) < 4 || len(args) > 6 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	receivedQuantity := ""
+	if len(args) > 4 {
+		receivedQuantity = args[4]
+	}
+	
+	receiptNumber := ""
+	if len(args) > 5 {
+		receiptNumber = args[5]
+	}
+	
+	actualMaterialInformationKey := "Ac-"+args[0]+"-"+args[1]
+	if receiptNumber != "" {
+		actualMaterialInformationKey = actualMaterialInformationKey+"-"+receiptNumber
+	}
+	
+	// Check if raw material actual date info already exists
+	if validateValue, validateErr := stub.GetState(actualMaterialInformationKey); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Actual Date for purchase order "+args[1]+" and material number "+args[0]+" already exists!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject != nil && !purchaseOrderObject.isActive() {
+		return Error(http.StatusConflict, "Purchase order "+args[1]+" is "+purchaseOrderObject.status())
+	}
+	
+	var expectedMaterialInformation ExpectedMaterialInformation
+	expectedMaterialInBytes, _ := stub.GetState("Ex-"+args[0]+"-"+args[1])
+	json.Unmarshal(expectedMaterialInBytes,&expectedMaterialInformation)
+	expectedMaterialInformation.MaterialNumber = args[0]
+	expectedMaterialInformation.Ex_PurchaseOrderNumber = args[1]
+	
+	receipts := getActualMaterialReceipts(stub,args[1],args[0])
+	receivedSoFar := newMaterialReceipts(expectedMaterialInformation,receipts)
+	if len(receipts) > 0 && receivedSoFar.isComplete {
+		return Error(http.StatusConflict, "Material number "+args[0]+" of purchase order "+args[1]+" is already delivered completely!")
+	}
+	
+	// partial receipts are checked against the ordered quantity
+	if receivedQuantity != "" {
+		if expectedMaterialInformation.OrderedQuantity == "" {
+			return Error(http.StatusNotAcceptable, "Received quantity needs the ordered quantity of the expected material information")
+		}
+		
+		quantity, ok := parsePositiveDecimal(receivedQuantity)
+		if !ok {
+			return Error(http.StatusNotAcceptable, "Received quantity must be a decimal number greater than 0")
+		}
+		
+		if new(big.Rat).Add(receivedSoFar.received,quantity).Cmp(receivedSoFar.ordered) > 0 {
+			return Error(http.StatusNotAcceptable, "Received quantity exceeds the open quantity "+quantityString(new(big.Rat).Sub(receivedSoFar.ordered,receivedSoFar.received)))
+		}
+	}
+	
+	actualMaterialInformationObject := &ActualMaterialInformation{
+		MaterialNumber: args[0],
+		Ac_PurchaseOrderNumber: args[1],
+		ActualDate: args[2],
+		DelayReason: args[3],
+		ReceiptNumber: receiptNumber,
+		ReceivedQuantity: receivedQuantity,
+	}	
+
+	// convert to byte
+	actualMaterialInformationObjectInBytes, _ := json.Marshal(actualMaterialInformationObject)
+
+	// write raw material info to BC
+	if err := stub.PutState(actualMaterialInformationKey, actualMaterialInformationObjectInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// rich queries do not see the receipt written in this transaction
+	receipts = append(receipts,*actualMaterialInformationObject)
+	
+	if purchaseOrderObject != nil {
+		if err := updatePurchaseOrderReceiptStatus(stub,*purchaseOrderObject,args[0],newMaterialReceipts(expectedMaterialInformation,receipts)); err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return Success(http.StatusCreated,"Material's actual delivery date information created successsfully!", nil)
+}
+
+/*
+ * Function for buyer or supplier to let the invoice chaincode issue the credit note for the delay penalty of a material
+ * once its goods receipt is recorded, or again once a dispute or waiver blocking it was decided. It is a transaction of
+ * its own, as a transaction does not read the receipt it records. Receipts and reschedules are passed to the invoice
+ * chaincode, which decides whether the penalty is final, e.g. no credit note is issued for deliveries on time, for
+ * partial deliveries or while a dispute is open.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *PurchaseOrder) issueCreditNote(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[1]+" not found")
+	}
+	
+	if _, response := getCallerParty(stub,*purchaseOrderObject); response.Status != http.StatusOK {
+		return response
+	}
+	
+	expectedMaterialInBytes, err := stub.GetState("Ex-"+args[0]+"-"+args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if expectedMaterialInBytes == nil {
+		return Error(http.StatusNotFound, "Material number "+args[0]+" of purchase order "+args[1]+" not found")
+	}
+	
+	var expectedMaterialInformation ExpectedMaterialInformation
+	json.Unmarshal(expectedMaterialInBytes,&expectedMaterialInformation)
+	
+	materialReceipts := newMaterialReceipts(expectedMaterialInformation,getActualMaterialReceipts(stub,args[1],args[0]))
+	purchaseOrderMaterialInBytes, _ := json.Marshal(newPurchaseOrderMaterial(*purchaseOrderObject,expectedMaterialInformation,materialReceipts))
+	
+	f := "issueCreditNote"
+	return invokeInvoiceChaincode(stub,f, args[1],args[0],string(purchaseOrderMaterialInBytes))
+}
+
+/*
+ * Function to get a material of a purchase order with its ordered quantity, unit price, goods receipts and reschedules.
+ * The invoice chaincode calls it to match invoices against purchase order and goods receipts and to calculate delays,
+ * the actual date is empty as long as not the whole ordered quantity is received.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *PurchaseOrder) getPurchaseOrderMaterial(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	purchaseOrderInBytes, err := stub.GetState(args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderInBytes == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[1]+" not found")
+	}
+	
+	var purchaseOrderObject PurchaseOrder
+	json.Unmarshal(purchaseOrderInBytes,&purchaseOrderObject)
+	
+	expectedMaterialInBytes, err := stub.GetState("Ex-"+args[0]+"-"+args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if expectedMaterialInBytes == nil {
+		return Error(http.StatusNotFound, "Material number "+args[0]+" of purchase order "+args[1]+" not found")
+	}
+	
+	var expectedMaterialInformation ExpectedMaterialInformation
+	json.Unmarshal(expectedMaterialInBytes,&expectedMaterialInformation)
+	
+	materialReceipts := newMaterialReceipts(expectedMaterialInformation,getActualMaterialReceipts(stub,args[1],args[0]))
+	purchaseOrderMaterial := newPurchaseOrderMaterial(purchaseOrderObject,expectedMaterialInformation,materialReceipts)
+	
+	purchaseOrderMaterialInBytes, _ := json.Marshal(purchaseOrderMaterial)
+	return Success(http.StatusOK, "OK", purchaseOrderMaterialInBytes)
+}
+
+/*
+ * Material of a purchase order with its receipts and reschedules as the invoice chaincode expects it.
+ */
+func newPurchaseOrderMaterial(purchaseOrderObject PurchaseOrder,expectedMaterialInformation ExpectedMaterialInformation,materialReceipts materialReceipts) (purchaseOrderMaterial PurchaseOrderMaterial) {
+	purchaseOrderMaterial = PurchaseOrderMaterial{
+		PurchaseOrderNumber: purchaseOrderObject.PurchaseOrderNumber,
+		SupplierCode: purchaseOrderObject.SupplierCode,
+		MaterialNumber: expectedMaterialInformation.MaterialNumber,
+		ExpectedDate: expectedMaterialInformation.ExpectedDate,
+		ActualDate: materialReceipts.actualDate(),
+		DelayReason: materialReceipts.latest.DelayReason,
+		OrderedQuantity: expectedMaterialInformation.OrderedQuantity,
+		UnitPrice: expectedMaterialInformation.UnitPrice,
+		IsComplete: len(materialReceipts.receipts) > 0 && materialReceipts.isComplete,
+		Receipts: []MaterialReceipt{},
+		Reschedules: expectedMaterialInformation.Reschedules,
+	}
+	if purchaseOrderMaterial.Reschedules == nil {
+		purchaseOrderMaterial.Reschedules = []RescheduleEvent{}
+	}
+	if line := purchaseOrderObject.lineForMaterial(expectedMaterialInformation.MaterialNumber); line != nil {
+		purchaseOrderMaterial.LineNumber = line.LineNumber
+		purchaseOrderMaterial.UnitOfMeasure = line.UnitOfMeasure
+	}
+	
+	// received quantity is only known for materials with ordered quantity
+	if expectedMaterialInformation.OrderedQuantity != "" {
+		purchaseOrderMaterial.ReceivedQuantity = quantityString(materialReceipts.received)
+	}
+	
+	for _, receipt := range materialReceipts.receipts {
+		purchaseOrderMaterial.Receipts = append(purchaseOrderMaterial.Receipts,MaterialReceipt{
+			ReceiptNumber: receipt.ReceiptNumber,
+			ActualDate: receipt.ActualDate,
+			ReceivedQuantity: receipt.ReceivedQuantity,
+			DelayReason: receipt.DelayReason,
+		})
+	}
+	
+	return
+}
+
+func (cc *PurchaseOrder) createMaterialTracking(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	
+	if len(args) != 8 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	
+	// Check if raw material expected date info already exists
+	if validateValue, validateErr := stub.GetState("TR-"+args[0]); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Tracking Number already exists")
+	}
+
+	trackOrderObject := &TrackOrder{
+		TrackingId: args[0],
+		TrackMaterialNumber: args[1],
+		TrackPurchaseOrderNumber: args[2],
+		SupplierFacilityName: args[3],
+		Timestamp: args[4],
+		TrackStatus: args[5],
+		TrackOrderState: args[6],
+		TrackOrderReason: args[7],
+	}
+	
+	// convert to byte
+	trackOrderObjectInBytes, _ := json.Marshal(trackOrderObject)
+
+	// write raw material info to BC
+	if err := stub.PutState("TR-"+args[0], trackOrderObjectInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusCreated,"Tracking Information Created Successsfully!", nil)
+}
+
+
+/*
+ * Function to get all purchase orders with their materials, invoices and penalties.
+ * optional: 1st - currency to report invoice amounts, penalties and totals in, defaults to each invoice's currency
+ */
+func (cc *PurchaseOrder) getAllPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	reportingCurrency := ""
+	if len(args) > 0 {
+		reportingCurrency = args[0]
+	}
+	
+	queryStringToGetAllPurchaseOrder := fmt.Sprintf("{\"selector\":{\"isPurchaseOrderObject\":true}}")
+	allPurchaseOrderResults, err := stub.GetQueryResult(queryStringToGetAllPurchaseOrder)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	defer allPurchaseOrderResults.Close()
+	
+	// buffer is a JSON array containing QueryResults
+	var buffer bytes.Buffer
+	buffer.WriteString("{ \"values\": [")
+	bArrayMemberAlreadyWritten := false
+	
+	for allPurchaseOrderResults.HasNext() {
+		queryResponse, err1 := allPurchaseOrderResults.Next()
+		if err1 != nil {
+			return shim.Error(err1.Error())
+		}
+		
+		// Add a comma before array members, suppress it for the first array member
+		if bArrayMemberAlreadyWritten == true {
+			buffer.WriteString(",")
+		}
+		
+		var purchaseOrderObject PurchaseOrder
+		json.Unmarshal(queryResponse.Value,&purchaseOrderObject)
+		buffer.WriteString("{")
+		
+		buffer = generatePurchaseOrderObject(purchaseOrderObject,buffer)
+		buffer.WriteString(",")
+		
+		buffer, err = getMaterialInformation(stub,purchaseOrderObject,reportingCurrency,buffer)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+		
+		buffer.WriteString("}")
+		bArrayMemberAlreadyWritten = true
+	}
+	
+	buffer.WriteString("]}")
+	return Success(200, "OK", buffer.Bytes())
+}
+
+/*
+ * Function to get one purchase order with its materials, invoices and penalties.
+ * 1st - purchase order #
+ * optional: 2nd - currency to report invoice amounts, penalties and totals in, defaults to each invoice's currency
+ */
+func (cc *PurchaseOrder) getAllMaterialInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+	reportingCurrency := ""
+	if len(args) > 1 {
+		reportingCurrency = args[1]
+	}
+	
+	purchaseOrderObject, err := getPurchaseOrderById(stub,args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if purchaseOrderObject == nil {
+		return Error(http.StatusNotFound, "Purchase order "+args[0]+" not found")
+	}
+	
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	buffer = generatePurchaseOrderObject(*purchaseOrderObject,buffer)
+	buffer.WriteString(",")
+	
+	buffer, err = getMaterialInformation(stub,*purchaseOrderObject,reportingCurrency,buffer)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	
+	buffer.WriteString("}")
+	return Success(200, "OK", buffer.Bytes())
+}
+
+func generatePurchaseOrderObject(purchaseOrderObject PurchaseOrder,buffer bytes.Buffer) (x bytes.Buffer) {
+	buffer.WriteString("\"purchaseOrderNumber\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.PurchaseOrderNumber)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"supplierCode\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.SupplierCode)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"supplierLocation\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.SupplierLocation)
+	buffer.WriteString("\"")
+	
+	lines := purchaseOrderObject.Lines
+	if lines == nil {
+		lines = []PurchaseOrderLine{}
+	}
+	linesInBytes, _ := json.Marshal(lines)
+	buffer.WriteString(", \"lines\":")
+	buffer.Write(linesInBytes)
+	
+	buffer.WriteString(", \"purchaseOrderStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.status())
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"statusReason\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.StatusReason)
+	buffer.WriteString("\"")
+	
+	changeOrders := purchaseOrderObject.ChangeOrders
+	if changeOrders == nil {
+		changeOrders = []ChangeOrder{}
+	}
+	changeOrdersInBytes, _ := json.Marshal(changeOrders)
+	buffer.WriteString(", \"changeOrders\":")
+	buffer.Write(changeOrdersInBytes)
+	
+	buffer.WriteString(", \"blanketOrderNumber\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(purchaseOrderObject.BlanketOrderNumber)
+	buffer.WriteString("\"")
+	
+	x = buffer
+	return
+}
+
+// delivery details of a material which are rolled up to purchase order level
+type materialDelivery struct {
+	actualDate string
+	delayReason string
+	orderedQuantity *big.Rat
+	receivedQuantity *big.Rat
+	status string
+	state string
+	currency string
+	invoiceAmount string
+	delayPenalty string
+}
+
+// sum of decimal amounts of one currency, it is dropped as soon as amounts of another currency are added
+type currencyTotal struct {
+	currency string
+	amount *big.Rat
+	isMixed bool
+}
+
+func (total *currencyTotal) add(currency string, amount string) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || total.isMixed {
+		return
+	}
+	
+	if total.amount == nil {
+		total.currency = currency
+		total.amount = new(big.Rat)
+	} else if total.currency != currency {
+		total.isMixed = true
+		return
+	}
+	
+	total.amount.Add(total.amount,value)
+}
+
+func (total *currencyTotal) String() string {
+	if total.amount == nil || total.isMixed {
+		return ""
+	}
+	// amounts are rounded by the invoice chaincode already, their sum has no more than two decimal places
+	return total.amount.FloatString(2)
+}
+
+func getMaterialInformation(stub shim.ChaincodeStubInterface,purchaseOrderObject PurchaseOrder,reportingCurrency string,buffer bytes.Buffer)(x bytes.Buffer, err error) {
+	// for material info
+	buffer.WriteString("\"expectedRawMaterialInformation\":")
+	buffer.WriteString("[")
+	
+	partInfoAlreadyWritten := false
+	queryString := fmt.Sprintf("{\"selector\":{\"isExpectedMaterialInfoObject\":true,\"ex_PurchaseOrderNumber\":\""+purchaseOrderObject.PurchaseOrderNumber+"\"}}")
+	expectedPartResultsIterator, expectedPartErr := stub.GetQueryResult(queryString)
+	
+	if expectedPartErr != nil {
+		return buffer, expectedPartErr
 	}
 	
 	defer expectedPartResultsIterator.Close()
 	
-	totalDelivered := 0, totalParts := 0, isEmptyActualDate := false, parentExpectedDate := "", parentStatus := "", parentActualDate := "", parentDelayReason := "", currMax := float64(0), parentState := ""
+	totalOrderedQuantity := new(big.Rat)
+	totalReceivedQuantity := new(big.Rat)
+	isEmptyActualDate := false
+	parentExpectedDate := ""
+	var latestDelivery materialDelivery
+	var totalInvoiceAmount, totalDelayPenalty currencyTotal
+	
+	for expectedPartResultsIterator.HasNext() {
+		expectedPartResponse, _ := expectedPartResultsIterator.Next()
+		
+		if partInfoAlreadyWritten == true {
+			buffer.WriteString(",")
+		}
+		
+		var expectedMaterialInformation ExpectedMaterialInformation
+		json.Unmarshal(expectedPartResponse.Value,&expectedMaterialInformation)
+		
+		if parentExpectedDate == "" {
+			parentExpectedDate = expectedMaterialInformation.ExpectedDate
+		}
+		
+		var delivery materialDelivery
+		buffer, delivery, err = getExpectedMaterialInformation(stub,purchaseOrderObject,expectedMaterialInformation,reportingCurrency,buffer)
+		if err != nil {
+			return buffer, err
+		}
+		
+		// pending penalties are not part of the totals
+		totalInvoiceAmount.add(delivery.currency,delivery.invoiceAmount)
+		totalDelayPenalty.add(delivery.currency,delivery.delayPenalty)
+		
+		// shipment status is measured on quantity, materials without ordered quantity count as one piece
+		totalOrderedQuantity.Add(totalOrderedQuantity,delivery.orderedQuantity)
+		totalReceivedQuantity.Add(totalReceivedQuantity,delivery.receivedQuantity)
+		
+		if delivery.actualDate == "" {
+			isEmptyActualDate = true
+		} else {
+			// status of the purchase order is the status of the material delivered last
+			latestActualDate, _ := time.Parse(timeFormat,latestDelivery.actualDate)
+			actualDate, _ := time.Parse(timeFormat,delivery.actualDate)
+			if latestDelivery.actualDate == "" || !actualDate.Before(latestActualDate) {
+				latestDelivery = delivery
+			}
+		}
+		
+		partInfoAlreadyWritten = true
+	}
+	
+	buffer.WriteString("]")
+	
+	if isEmptyActualDate == true {		// when actual date is not present or is empty, set status, actual date and delay reason at order level
+		buffer.WriteString(", \"parentStatus\":")
+		buffer.WriteString("\"")
+		buffer.WriteString("On-Time")
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"state\":")
+		buffer.WriteString("\"")
+		buffer.WriteString("Success")
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"parentActualDate\":")
+		buffer.WriteString("\"")
+		buffer.WriteString("")
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"delayReason\":")
+		buffer.WriteString("\"")
+		buffer.WriteString("No delay")
+		buffer.WriteString("\"")
+	} else {							// when actual date is present, set status, actual date and delay reason at order level
+		buffer.WriteString(", \"parentStatus\":")
+		buffer.WriteString("\"")
+		buffer.WriteString(latestDelivery.status)
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"state\":")
+		buffer.WriteString("\"")
+		buffer.WriteString(latestDelivery.state)
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"parentActualDate\":")
+		buffer.WriteString("\"")
+		buffer.WriteString(latestDelivery.actualDate)
+		buffer.WriteString("\"")
+		
+		buffer.WriteString(", \"delayReason\":")
+		buffer.WriteString("\"")
+		buffer.WriteString(latestDelivery.delayReason)
+		buffer.WriteString("\"")
+	}
+	
+	buffer.WriteString(", \"parentExpectedDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(parentExpectedDate)
+	buffer.WriteString("\"")
+	
+	// share of the ordered quantity received, it is no money amount and FloatString rounds its last decimal half up
+	overAllShipmentPercent := new(big.Rat)
+	if totalOrderedQuantity.Sign() > 0 {
+		overAllShipmentPercent.Quo(totalReceivedQuantity,totalOrderedQuantity)
+		overAllShipmentPercent.Mul(overAllShipmentPercent,big.NewRat(100,1))
+	}
+	buffer.WriteString(", \"overAllShipmentStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(overAllShipmentPercent.FloatString(2))
+	buffer.WriteString("\"")
+	
+	// totals are only given when all invoices of the purchase order are reported in the same currency
+	buffer.WriteString(", \"reportingCurrency\":")
+	buffer.WriteString("\"")
+	if !totalInvoiceAmount.isMixed {
+		buffer.WriteString(totalInvoiceAmount.currency)
+	}
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"totalInvoiceAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(totalInvoiceAmount.String())
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"totalDelayPenalty\":")
+	buffer.WriteString("\"")
+	if !totalInvoiceAmount.isMixed {
+		buffer.WriteString(totalDelayPenalty.String())
+	}
+	buffer.WriteString("\"")
+	
+	x = buffer
+	return
+}
+
+func getExpectedMaterialInformation(stub shim.ChaincodeStubInterface,purchaseOrderObject PurchaseOrder,expectedMaterialInformation ExpectedMaterialInformation,reportingCurrency string,buffer bytes.Buffer) (x bytes.Buffer, delivery materialDelivery, err error) {
+	purchaseOrderNumber := purchaseOrderObject.PurchaseOrderNumber
+	
+	buffer.WriteString("{\"rawMaterialNumber\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.MaterialNumber)
+	buffer.WriteString("\"")
+	buffer.WriteString(",")
+
+	buffer = getTrackingInfo(stub,purchaseOrderNumber,expectedMaterialInformation.MaterialNumber,buffer)
+
+	materialReceipts := newMaterialReceipts(expectedMaterialInformation,getActualMaterialReceipts(stub,purchaseOrderNumber,expectedMaterialInformation.MaterialNumber))
+	
+	buffer.WriteString(", \"delayReason\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(materialReceipts.latest.DelayReason)
+	buffer.WriteString("\"")
+	
+	// material is delivered once the whole ordered quantity is received
+	buffer.WriteString(", \"actualDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(materialReceipts.actualDate())
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"orderedQuantity\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.OrderedQuantity)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"unitPrice\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.UnitPrice)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"receivedQuantity\":")
+	buffer.WriteString("\"")
+	if expectedMaterialInformation.OrderedQuantity != "" {
+		buffer.WriteString(quantityString(materialReceipts.received))
+	}
+	buffer.WriteString("\"")
+	
+	receiptsInBytes, _ := json.Marshal(materialReceipts.receipts)
+	buffer.WriteString(", \"receipts\":")
+	buffer.Write(receiptsInBytes)
+	
+	buffer.WriteString(", \"expectedDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.ExpectedDate)
+	buffer.WriteString("\"")
+	
+	// expected date stays empty until the proposed date is confirmed
+	buffer.WriteString(", \"proposedDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.ProposedDate)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"dateStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(expectedMaterialInformation.dateStatus())
+	buffer.WriteString("\"")
+	
+	reschedules := expectedMaterialInformation.Reschedules
+	if reschedules == nil {
+		reschedules = []RescheduleEvent{}
+	}
+	reschedulesInBytes, _ := json.Marshal(reschedules)
+	buffer.WriteString(", \"reschedules\":")
+	buffer.Write(reschedulesInBytes)
+	
+	// reschedule waiting for the confirmation of the other party, null when there is none
+	pendingRescheduleInBytes, _ := json.Marshal(expectedMaterialInformation.PendingReschedule)
+	buffer.WriteString(", \"pendingReschedule\":")
+	buffer.Write(pendingRescheduleInBytes)
+	
+	var invoice Invoice
+	buffer, invoice, err = getInvoiceInformation(stub,purchaseOrderObject,expectedMaterialInformation,materialReceipts,reportingCurrency,buffer)
+	if err != nil {
+		return buffer, delivery, err
+	}
+	buffer.WriteString("}")
+
+	delivery = materialDelivery{
+		actualDate: materialReceipts.actualDate(),
+		delayReason: materialReceipts.latest.DelayReason,
+		orderedQuantity: materialReceipts.ordered,
+		receivedQuantity: materialReceipts.received,
+		status: invoice.Status,
+		state: invoice.State,
+		currency: invoice.ReportingCurrency,
+		invoiceAmount: invoice.ReportingInvoiceAmount,
+		delayPenalty: invoice.ReportingDelayPenalty,
+	}
+	
+	x = buffer
+	return
+}
+
+func getInvoiceInformation(stub shim.ChaincodeStubInterface,purchaseOrderObject PurchaseOrder,expectedMaterialInformation ExpectedMaterialInformation,materialReceipts materialReceipts,reportingCurrency string,buffer bytes.Buffer) (x bytes.Buffer, invoice Invoice, err error) {
+	// Check if invoice exists, the invoice chaincode cannot call back this chaincode, so expected date, receipts and
+	// reschedules of the material are passed to it. It picks the penalty contract in force for the supplier, waives the
+	// penalty as far as the contract exempts the coded delay reason and pro-rates it on the receipts
+	purchaseOrderMaterialInBytes, _ := json.Marshal(newPurchaseOrderMaterial(purchaseOrderObject,expectedMaterialInformation,materialReceipts))
+	
+	f := "getInvoiceAmountById"
+	invoiceResponse := invokeInvoiceChaincode(stub,f, purchaseOrderObject.PurchaseOrderNumber,expectedMaterialInformation.MaterialNumber,string(purchaseOrderMaterialInBytes),"",reportingCurrency)
+	if invoiceResponse.Status != http.StatusOK {
+		return buffer, invoice, fmt.Errorf("invoice of material number %s of purchase order %s could not be read: %s", expectedMaterialInformation.MaterialNumber, purchaseOrderObject.PurchaseOrderNumber, invoiceResponse.Message)
+	}
+	
+	// payload is empty as long as no invoice was created for the material
+	if len(invoiceResponse.Payload) > 0 {
+		if err = json.Unmarshal(invoiceResponse.Payload, &invoice); err != nil {
+			return buffer, invoice, err
+		}
+	}
+	
+	buffer.WriteString(", \"invoiceAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.InvoiceAmount)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"invoiceStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.InvoiceStatus)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"status\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.Status)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"state\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.State)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"delayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.DelayPenalty)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"contractId\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.ContractId)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"penaltyBaselineDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.PenaltyBaselineDate)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"exemption\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.Exemption)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"waivedDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.WaivedDelayPenalty)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"waiverStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.WaiverStatus)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"waiverDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.WaiverDelayPenalty)
+	buffer.WriteString("\"")
 	
-	for expectedPartResultsIterator.HasNext() {
-		totalParts = totalParts + 1;
-		expectedPartResponse, _ := expectedPartResultsIterator.Next()
-		
-		if partInfoAlreadyWritten == true {
-			buffer.WriteString(",")
-		}
-		
-		var expectedMaterialInformation ExpectedMaterialInformation
-		json.Unmarshal(expectedPartResponse.Value,&expectedMaterialInformation)
-		
-		buffer = getMaterialInformation(purchaseOrderNumber,expectedMaterialInformation,buffer)
-		
-		partInfoAlreadyWritten = true
-	}
+	buffer.WriteString(", \"disputeStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.DisputeStatus)
+	buffer.WriteString("\"")
 	
-	x = buffer
-	return
-}
-
-func getMaterialInformation(purchaseOrderNumber string,expectedMaterialInformation ExpectedMaterialInformation,buffer bytes.Buffer) (x bytes.Buffer) {
-	buffer.WriteString("{\"rawMaterialNumber\":")
+	buffer.WriteString(", \"creditNoteNumber\":")
 	buffer.WriteString("\"")
-	buffer.WriteString(expectedMaterialInformation.MaterialNumber)
+	buffer.WriteString(invoice.CreditNoteNumber)
 	buffer.WriteString("\"")
-	buffer.WriteString(",")
-
-	buffer = getTrackingInfo(purchaseOrderNumber,expectedMaterialInformation.MaterialNumber,buffer)
-	buffer.WriteString(",")
-
-	actualDate := ""
-
-	buffer,actualDate = getActualDateInformationForMaterial(purchaseOrderNumber,expectedMaterialInformation.MaterialNumber,buffer)
-	buffer.WriteString(",")
-
-	buffer = getInvoiceInformation(purchaseOrderNumber,expectedMaterialInformation,actualDate,buffer)
-	buffer.WriteString(",")
-
-	x = buffer
-	return
-}
-
-func getInvoiceInformation(purchaseOrderNumber string,expectedMaterialInformation ExpectedMaterialInformation,actualDate string,buffer bytes.Buffer) (x bytes.Buffer) {
-	// Check if invoice exists
-	f := "getInvoiceAmountById"
-	queryArgs := toChaincodeArgs(f, purchaseOrderNumber,expectedMaterialInformation.MaterialNumber,expectedMaterialInformation.ExpectedDate,actualDate)
-
-	invoiceResponse := stub.InvokeChaincode("a3596e82-9760-494a-bad7-31ffc9530b7e-com-sap-icn-blockchain-invoice-penalty-scenario",queryArgs,"")	
-
-	var invoice Invoice
-	json.Unmarshal(invoiceResponse.Payload, &invoice)
 	
-	buffer.WriteString("\"invoiceAmount\":")
+	buffer.WriteString(", \"creditNoteStatus\":")
 	buffer.WriteString("\"")
-	buffer.WriteString(invoice.InvoiceAmount)
+	buffer.WriteString(invoice.CreditNoteStatus)
 	buffer.WriteString("\"")
 	
-	buffer.WriteString("\"delayPenalty\":")
+	buffer.WriteString(", \"reportingCurrency\":")
 	buffer.WriteString("\"")
-	buffer.WriteString(invoice.DelayPenalty)
+	buffer.WriteString(invoice.ReportingCurrency)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"reportingInvoiceAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.ReportingInvoiceAmount)
+	buffer.WriteString("\"")
+	
+	buffer.WriteString(", \"reportingDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoice.ReportingDelayPenalty)
 	buffer.WriteString("\"")
 
-	timeFormat := "01/02/2006"
-	stateOfDelivery := ""
+	x = buffer
+	return
 }
 
-func getActualDateInformationForMaterial(purchaseOrderNumber string, materialNumber string,buffer bytes.Buffer) (x bytes.Buffer,actualDate string,) {
-	queryString = fmt.Sprintf("{\"selector\":{\"ac_PurchaseOrderNumber\":\""+purchaseOrderNumber+"\",\"materialNumber\":\""+materialNumber+"\"}}")
+func getActualMaterialReceipts(stub shim.ChaincodeStubInterface,purchaseOrderNumber string, materialNumber string) (receipts []ActualMaterialInformation) {
+	receipts = []ActualMaterialInformation{}
+	queryString := fmt.Sprintf("{\"selector\":{\"ac_PurchaseOrderNumber\":\""+purchaseOrderNumber+"\",\"materialNumber\":\""+materialNumber+"\"}}")
 			
-	actualPartResultsIterator, _ := stub.GetQueryResult(queryString)
+	actualPartResultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		return
+	}
 	defer actualPartResultsIterator.Close()
 	
-	actualDate := ""
-	delayReasonTemp := ""
-	
 	for actualPartResultsIterator.HasNext() {
 		actualPartResponse, _ := actualPartResultsIterator.Next()
 		
 		var actualMaterialInfo ActualMaterialInformation
 		json.Unmarshal(actualPartResponse.Value,&actualMaterialInfo)
 		
-		buffer.WriteString(", \"delayReason\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(actualMaterialInfo.DelayReason)
-		buffer.WriteString("\"")
-		delayReasonTemp = actualMaterialInfo.DelayReason
-		
-		buffer.WriteString(", \"actualDate\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(actualMaterialInfo.ActualDate)
-		buffer.WriteString("\"")
-		
-		actualDate = actualMaterialInfo.ActualDate
+		receipts = append(receipts,actualMaterialInfo)
+	}
+	return
+}
+
+// receipts of a material summed up against its ordered quantity, a material without ordered quantity counts as one piece
+type materialReceipts struct {
+	receipts []ActualMaterialInformation
+	latest ActualMaterialInformation
+	ordered *big.Rat
+	received *big.Rat
+	isComplete bool
+}
+
+/*
+ * Sum up the receipts of a material, a receipt without quantity delivers the remaining quantity.
+ */
+func newMaterialReceipts(expectedMaterialInformation ExpectedMaterialInformation,receipts []ActualMaterialInformation) (materialReceipts materialReceipts) {
+	materialReceipts.receipts = receipts
+	materialReceipts.ordered = big.NewRat(1,1)
+	materialReceipts.received = new(big.Rat)
+	
+	if orderedQuantity, ok := parsePositiveDecimal(expectedMaterialInformation.OrderedQuantity); ok {
+		materialReceipts.ordered = orderedQuantity
+	}
+	
+	for _, receipt := range receipts {
+		if receivedQuantity, ok := parsePositiveDecimal(receipt.ReceivedQuantity); ok {
+			materialReceipts.received.Add(materialReceipts.received,receivedQuantity)
+		} else {
+			materialReceipts.isComplete = true
+		}
 		
-		break;
+		// delay reason and actual date of the material are the ones of the receipt delivered last
+		latestActualDate, _ := time.Parse(timeFormat,materialReceipts.latest.ActualDate)
+		actualDate, _ := time.Parse(timeFormat,receipt.ActualDate)
+		if materialReceipts.latest.ActualDate == "" || !actualDate.Before(latestActualDate) {
+			materialReceipts.latest = receipt
+		}
+	}
+	
+	if materialReceipts.isComplete || materialReceipts.received.Cmp(materialReceipts.ordered) >= 0 {
+		materialReceipts.isComplete = true
+		materialReceipts.received.Set(materialReceipts.ordered)
 	}
-	x = buffer
 	return
 }
 
-func getTrackingInfo(purchaseOrderNumber string, materialNumber string,buffer bytes.Buffer) (x bytes.Buffer) {
-	queryString = fmt.Sprintf("{\"selector\":{\"trackPurchaseOrderNumber\":\""+purchaseOrderNumber+"\",\"trackMaterialNumber\":\""+materialNumber+"\"}}")
-	trackingPartResultsIterator, _ := stub.GetQueryResult(queryString)
-	defer trackingPartResultsIterator.Close()
+/*
+ * Actual date of the material, it is empty as long as not the whole ordered quantity is received.
+ */
+func (materialReceipts materialReceipts) actualDate() string {
+	if !materialReceipts.isComplete {
+		return ""
+	}
+	return materialReceipts.latest.ActualDate
+}
+
+func getTrackingInfo(stub shim.ChaincodeStubInterface,purchaseOrderNumber string, materialNumber string,buffer bytes.Buffer) (x bytes.Buffer) {
+	queryString := fmt.Sprintf("{\"selector\":{\"trackPurchaseOrderNumber\":\""+purchaseOrderNumber+"\",\"trackMaterialNumber\":\""+materialNumber+"\"}}")
 	
 	buffer.WriteString("\"trackingInfo\":")
 	buffer.WriteString("[")
 	
+	trackingPartResultsIterator, err := stub.GetQueryResult(queryString)
+	if err != nil {
+		buffer.WriteString("]")
+		x = buffer
+		return
+	}
+	defer trackingPartResultsIterator.Close()
+	
 	isTrackingInfoPresent := false
 	
 	for trackingPartResultsIterator.HasNext() {
@@ -454,578 +2777,3 @@ func getTrackingInfo(purchaseOrderNumber string, materialNumber string,buffer by
 	x = buffer
 	return
 }
-
-
-
-
-
-
-func (cc *Demand) getAllDemand(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-
-	queryString := fmt.Sprintf("{\"selector\":{\"demandInfo\":\"true\"}}")
-	resultsIterator, err := stub.GetQueryResult(queryString)
-	if err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
-	}
-	defer resultsIterator.Close()
-
-	// buffer is a JSON array containing QueryResults
-	var buffer bytes.Buffer
-	buffer.WriteString("{ \"values\": [")
-
-	bArrayMemberAlreadyWritten := false
-	
-	for resultsIterator.HasNext() {
-		queryResponse, err1 := resultsIterator.Next()
-		if err1 != nil {
-			return shim.Error(err1.Error())
-		}
-		
-		// Add a comma before array members, suppress it for the first array member
-		if bArrayMemberAlreadyWritten == true {
-			buffer.WriteString(",")
-		}
-		
-		var demandInfoObject Demand
-		json.Unmarshal(queryResponse.Value,&demandInfoObject)
-		
-		buffer.WriteString("{\"demandNumber\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(demandInfoObject.DemandNumber)
-		buffer.WriteString("\"")
-		
-		buffer.WriteString(", \"supplierCode\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(demandInfoObject.SupplierCode)
-		buffer.WriteString("\"")
-		
-		buffer.WriteString(", \"supplierLocation\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(demandInfoObject.SupplierLocation)
-		buffer.WriteString("\"")
-		
-		// for raw material info
-		buffer.WriteString(", \"expectedRawMaterialInformation\":")
-		buffer.WriteString("[")
-		
-		partInfoAlreadyWritten := false
-		queryString = fmt.Sprintf("{\"selector\":{\"rawMaterialExpectedInfo\":\"true\",\"demandNumber\":\""+(demandInfoObject.DemandNumber)+"\"}}")
-		expectedPartResultsIterator, expectedPartErr := stub.GetQueryResult(queryString)
-		if expectedPartErr != nil {
-			return Error(http.StatusInternalServerError, expectedPartErr.Error())
-		}
-		defer expectedPartResultsIterator.Close()
-		
-		totalDelivered := 0 
-		totalParts := 0
-		isEmptyActualDate := false
-		parentExpectedDate := ""	
-		
-		parentStatus := ""
-		parentActualDate := ""
-		parentDelayReason := ""
-		currMax := float64(0)
-		parentState := ""
-		
-		for expectedPartResultsIterator.HasNext() {
-			totalParts = totalParts + 1;
-			expectedPartResponse, _ := expectedPartResultsIterator.Next()
-			
-			if partInfoAlreadyWritten == true {
-				buffer.WriteString(",")
-			}
-			
-			var expectedRawMaterialInfo RawMaterialExpectedInformation
-			json.Unmarshal(expectedPartResponse.Value,&expectedRawMaterialInfo)
-			
-			if parentExpectedDate == "" {
-				parentExpectedDate = expectedRawMaterialInfo.ExpectedDate
-			}
-			
-			buffer.WriteString("{\"rawMaterialNumber\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(expectedRawMaterialInfo.RawMaterialNumber)
-			buffer.WriteString("\"")
-			
-			// fetch tracking
-			queryString = fmt.Sprintf("{\"selector\":{\"trackOrderNumber\":\""+(demandInfoObject.DemandNumber)+"\",\"trackPartNumber\":\""+(expectedRawMaterialInfo.RawMaterialNumber)+"\"}}")
-			trackingPartResultsIterator, _ := stub.GetQueryResult(queryString)
-			defer trackingPartResultsIterator.Close()
-			
-			buffer.WriteString(", \"trackingInfo\":")
-			buffer.WriteString("[")
-			
-			isTrackingInfoPresent := false
-			
-			for trackingPartResultsIterator.HasNext() {
-				trackingPartResponse, _ := trackingPartResultsIterator.Next()
-				
-				var trackingInfo TrackOrder
-				json.Unmarshal(trackingPartResponse.Value,&trackingInfo)
-				
-				if isTrackingInfoPresent == true {
-					buffer.WriteString(",")
-				}
-				
-				buffer.WriteString("{\"supplierFacilityName\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(trackingInfo.SupplierFacilityName)
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"trackStatus\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(trackingInfo.TrackStatus)
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"reason\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(trackingInfo.Reason)
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"state\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(trackingInfo.State)
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"timestamp\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(trackingInfo.Timestamp)
-				buffer.WriteString("\"}")
-				
-				isTrackingInfoPresent = true
-			}
-			
-			buffer.WriteString("]")
-			
-			queryString = fmt.Sprintf("{\"selector\":{\"rawMaterialActualInfo\":\"true\",\"demandNumber\":\""+(demandInfoObject.DemandNumber)+"\",\"rawMaterialNumber\":\""+(expectedRawMaterialInfo.RawMaterialNumber)+"\"}}")
-			
-			actualPartResultsIterator, _ := stub.GetQueryResult(queryString)
-			defer actualPartResultsIterator.Close()
-			
-			actualDate := ""
-			delayReasonTemp := ""
-			
-			for actualPartResultsIterator.HasNext() {
-				actualPartResponse, _ := actualPartResultsIterator.Next()
-				
-				var actualRawMaterialInfo RawMaterialActualInformation
-				json.Unmarshal(actualPartResponse.Value,&actualRawMaterialInfo)
-				
-				buffer.WriteString(", \"delayReason\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(actualRawMaterialInfo.DelayReason)
-				buffer.WriteString("\"")
-				delayReasonTemp = actualRawMaterialInfo.DelayReason
-				
-				buffer.WriteString(", \"actualDate\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(actualRawMaterialInfo.ActualDate)
-				buffer.WriteString("\"")
-				
-				actualDate = actualRawMaterialInfo.ActualDate
-				
-				break;
-			}
-			
-			// Check if invoice exists
-			f := "getInvoiceAmountById"
-			queryArgs := toChaincodeArgs(f, demandInfoObject.DemandNumber,expectedRawMaterialInfo.RawMaterialNumber)
-
-			invoiceResponse := stub.InvokeChaincode("a3596e82-9760-494a-bad7-31ffc9530b7e-com-sap-icn-blockchain-invoice-penalty-scenario",queryArgs,"")	
-
-			var invoice Invoice
-			json.Unmarshal(invoiceResponse.Payload, &invoice)
-			
-			buffer.WriteString(", \"invoiceAmount\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(invoice.InvoiceAmount)
-			buffer.WriteString("\"")
-			
-			timeFormat := "01/02/2006"
-			stateOfDelivery := ""
-			
-			if parentActualDate == "" {
-				parentActualDate = actualDate
-			}
-			
-			if expectedRawMaterialInfo.ExpectedDate != "" && actualDate != "" {
-				totalDelivered = totalDelivered + 1;
-				ed, _ := time.Parse(timeFormat,expectedRawMaterialInfo.ExpectedDate)
-				ad, _ := time.Parse(timeFormat,actualDate)
-				
-				duration := ad.Sub(ed)
-				days := (duration.Hours())/24
-				xy := ""
-				
-				if days == float64(0) {
-					buffer.WriteString(", \"status\":")
-					buffer.WriteString("\"")
-					buffer.WriteString("Delivered")
-					buffer.WriteString("\"")
-					xy = "Delivered"
-					stateOfDelivery = "None"
-					
-					buffer.WriteString(", \"state\":")
-					buffer.WriteString("\"")
-					buffer.WriteString("None")
-					buffer.WriteString("\"")
-					
-					buffer.WriteString(", \"delayPenalty\":")
-					buffer.WriteString("\"")
-					buffer.WriteString("0.00")
-					buffer.WriteString("\"")
-				} else {
-					buffer.WriteString(", \"status\":")
-					buffer.WriteString("\"")
-					x := fmt.Sprintf("%.0f",days)
-					strForStatus := "Delivered+"+x
-					buffer.WriteString(strForStatus)
-					buffer.WriteString("\"")
-					xy = "Delivered+"+x
-					stateOfDelivery = "Error"
-					
-					buffer.WriteString(", \"state\":")
-					buffer.WriteString("\"")
-					buffer.WriteString("Error")
-					buffer.WriteString("\"")
-					
-					penaltyPercentage := 0
-					
-					if 	days > float64(0) && days <= float64(2) {
-						penaltyPercentage = 5
-					}else if days > float64(2) && days <= float64(7) {
-						penaltyPercentage = 10
-					}else if days > float64(7) {
-						penaltyPercentage = 20
-					}
-					
-					InvoiceAmountFloat, _ := strconv.ParseFloat((invoice.InvoiceAmount),32)
-					InvoicePenalty := (InvoiceAmountFloat*float64(penaltyPercentage)) / 100
-					invoicePenaltyStr := fmt.Sprintf("%.2f", InvoicePenalty );
-					
-					buffer.WriteString(", \"delayPenalty\":")
-					buffer.WriteString("\"")
-					buffer.WriteString(invoicePenaltyStr)
-					buffer.WriteString("\"")
-					
-				}
-				
-				if parentActualDate != "" {
-					edLatest, _ := time.Parse(timeFormat,parentActualDate)
-					adLatest, _ := time.Parse(timeFormat,actualDate)
-					
-					durationLatest := adLatest.Sub(edLatest)
-					latestDays := (durationLatest.Hours())/24
-					
-					if currMax <= latestDays {
-						currMax = latestDays
-						parentActualDate = actualDate
-						parentStatus = xy
-						parentDelayReason = delayReasonTemp
-						parentState = stateOfDelivery
-					}
-					
-				} else {
-					parentActualDate = actualDate
-					parentStatus = xy
-					parentDelayReason = delayReasonTemp
-					parentState = "None"
-				}
-			} else {
-				isEmptyActualDate = true
-				buffer.WriteString(", \"delayReason\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("")
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"actualDate\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("")
-				buffer.WriteString("\"")
-			
-				buffer.WriteString(", \"status\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("On-Time")
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"state\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("Success")
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"delayPenalty\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("0.00")
-				buffer.WriteString("\"")
-			}
-			
-			buffer.WriteString(", \"expectedDate\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(expectedRawMaterialInfo.ExpectedDate)
-			buffer.WriteString("\"}")
-			
-			partInfoAlreadyWritten = true
-			
-		}
-		
-		buffer.WriteString("]")
-		
-		if isEmptyActualDate == true {		// when actual date is not present or is empty, set status, actual date and delay reason at order level
-			parentStatus = "On-Time"
-			buffer.WriteString(", \"parentStatus\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(parentStatus)
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"state\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("Success")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"actualDate\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"delayReason\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("No delay")
-			buffer.WriteString("\"")
-		} else {							// when actual date is present, set status, actual date and delay reason at order level
-			buffer.WriteString(", \"parentStatus\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(parentStatus)
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"state\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(parentState)
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"parentActualDate\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(parentActualDate)
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"delayReason\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(parentDelayReason)
-			buffer.WriteString("\"")
-		}
-		
-		buffer.WriteString(", \"parentExpectedDate\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(parentExpectedDate)
-		buffer.WriteString("\"")
-		
-		overAllShipmentPercent := (float64(totalDelivered)/float64(totalParts) * 100)
-		buffer.WriteString(", \"overAllShipmentStatus\":")
-		buffer.WriteString("\"")
-		shipmentPercent := fmt.Sprintf("%.2f",overAllShipmentPercent)
-		buffer.WriteString(shipmentPercent)
-		buffer.WriteString("\"}")
-		
-		bArrayMemberAlreadyWritten = true
-	}
-	
-	buffer.WriteString("]}")
-	return Success(200, "OK", buffer.Bytes())
-}
-
-/*func (cc *Demand) getPartByDemandNumber(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 1 {
-		return Error(http.StatusNotAcceptable, "Invalid parameters!")
-	}
-	return shim.Success(nil)
-}*/
-
-
-
-func (cc *Demand) getAllPartNumber(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	queryString := fmt.Sprintf("{\"selector\":{\"rawMaterialExpectedInfo\":\"true\"}}")
-	
-	resultsIterator, err := stub.GetQueryResult(queryString)
-	if err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
-	}
-	defer resultsIterator.Close()
-
-	// buffer is a JSON array containing QueryResults
-	var buffer bytes.Buffer
-	buffer.WriteString("{ \"values\": [")
-
-	bArrayMemberAlreadyWritten := false
-	
-	for resultsIterator.HasNext() {
-		queryResponse, err1 := resultsIterator.Next()
-		if err1 != nil {
-			return shim.Error(err1.Error())
-		}
-		
-		// Add a comma before array members, suppress it for the first array member
-		if bArrayMemberAlreadyWritten == true {
-			buffer.WriteString(",")
-		}
-		
-		var expectedRawMaterialInfo RawMaterialExpectedInformation
-		json.Unmarshal(queryResponse.Value,&expectedRawMaterialInfo)
-		
-		buffer.WriteString("{\"rawMaterialNumber\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(expectedRawMaterialInfo.RawMaterialNumber)
-		buffer.WriteString("\"")
-		
-		buffer.WriteString(", \"expectedDate\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(expectedRawMaterialInfo.ExpectedDate)
-		buffer.WriteString("\"")
-		
-		buffer.WriteString(", \"demandNumber\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(expectedRawMaterialInfo.DemandNumber)
-		buffer.WriteString("\"")
-		
-		queryString = fmt.Sprintf("{\"selector\":{\"rawMaterialActualInfo\":\"true\",\"demandNumber\":\""+(expectedRawMaterialInfo.DemandNumber)+"\",\"rawMaterialNumber\":\""+(expectedRawMaterialInfo.RawMaterialNumber)+"\"}}")
-			
-		actualPartResultsIterator, _ := stub.GetQueryResult(queryString)
-		defer actualPartResultsIterator.Close()
-		
-		actualDate := ""
-		
-		for actualPartResultsIterator.HasNext() {
-			actualPartResponse, _ := actualPartResultsIterator.Next()
-			
-			var actualRawMaterialInfo RawMaterialActualInformation
-			json.Unmarshal(actualPartResponse.Value,&actualRawMaterialInfo)
-			
-			buffer.WriteString(", \"delayReason\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(actualRawMaterialInfo.DelayReason)
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"actualDate\":")
-			buffer.WriteString("\"")
-			buffer.WriteString(actualRawMaterialInfo.ActualDate)
-			buffer.WriteString("\"")
-			
-			actualDate = actualRawMaterialInfo.ActualDate
-			
-			break;
-		}
-		
-		// Check if invoice exists
-		f := "getInvoiceAmountById"
-		queryArgs := toChaincodeArgs(f, expectedRawMaterialInfo.DemandNumber,expectedRawMaterialInfo.RawMaterialNumber)
-
-		invoiceResponse := stub.InvokeChaincode("a3596e82-9760-494a-bad7-31ffc9530b7e-com-sap-icn-blockchain-invoice-penalty-scenario",queryArgs,"")	
-
-		var invoice Invoice
-		json.Unmarshal(invoiceResponse.Payload, &invoice)
-		
-		buffer.WriteString(", \"invoiceAmount\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(invoice.InvoiceAmount)
-		buffer.WriteString("\"")
-		
-		timeFormat := "01/02/2006"
-		
-		if expectedRawMaterialInfo.ExpectedDate != "" && actualDate != "" {
-			ed, _ := time.Parse(timeFormat,expectedRawMaterialInfo.ExpectedDate)
-			ad, _ := time.Parse(timeFormat,actualDate)
-			
-			duration := ad.Sub(ed)
-			days := (duration.Hours())/24
-			
-			if days == 0 {
-				buffer.WriteString(", \"status\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("Delivered")
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"state\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("None")
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"delayPenalty\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("0.00")
-				buffer.WriteString("\"")
-			} else {
-				buffer.WriteString(", \"status\":")
-				buffer.WriteString("\"")
-				x := fmt.Sprintf("%.0f",days)
-				buffer.WriteString("Delivered+"+x)
-				buffer.WriteString("\"")
-				
-				buffer.WriteString(", \"state\":")
-				buffer.WriteString("\"")
-				buffer.WriteString("Error")
-				buffer.WriteString("\"")
-				
-				penaltyPercentage := 0
-				
-				if 	days > 0 && days <= 2 {
-					penaltyPercentage = 5
-				}else if days >2 && days <= 7{
-					penaltyPercentage = 10
-				}else if days > 7{
-					penaltyPercentage = 20
-				}
-				
-				InvoiceAmountFloat, _ := strconv.ParseFloat((invoice.InvoiceAmount),32)
-				InvoicePenalty := (InvoiceAmountFloat*float64(penaltyPercentage)) / 100
-				invoicePenaltyStr := fmt.Sprintf("%.2f", InvoicePenalty );
-				
-				buffer.WriteString(", \"delayPenalty\":")
-				buffer.WriteString("\"")
-				buffer.WriteString(invoicePenaltyStr)
-				buffer.WriteString("\"")
-				
-			}
-		} else {
-			buffer.WriteString(", \"delayReason\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"actualDate\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"state\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("Success")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"status\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("On-Time")
-			buffer.WriteString("\"")
-			
-			buffer.WriteString(", \"delayPenalty\":")
-			buffer.WriteString("\"")
-			buffer.WriteString("0.00")
-			buffer.WriteString("\"")
-		}
-		
-		demandValues, validateErr := stub.GetState(expectedRawMaterialInfo.DemandNumber)
-		if validateErr != nil {
-			return Error(http.StatusInternalServerError,validateErr.Error())
-		}
-		
-		var demandInfo Demand
-		json.Unmarshal(demandValues,&demandInfo)
-		
-		buffer.WriteString(", \"supplierCode\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(demandInfo.SupplierCode)
-		buffer.WriteString("\"}")
-		
-		bArrayMemberAlreadyWritten = true
-	}
-	
-	buffer.WriteString("]}")
-	return Success(200, "OK", buffer.Bytes())
-}
\ No newline at end of file
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// ledger of a test with the state read and written by key, queries fail like on a peer without CouchDB
+type stateStub struct {
+	shim.ChaincodeStubInterface
+	state map[string][]byte
+}
+
+func (stub *stateStub) GetState(key string) ([]byte, error) {
+	return stub.state[key], nil
+}
+
+func (stub *stateStub) PutState(key string, value []byte) error {
+	stub.state[key] = value
+	return nil
+}
+
+func (stub *stateStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("rich queries are not supported")
+}
+
+// stub with the expected material information of materials of a purchase order
+func newStateStub(t *testing.T, expectedMaterials ...ExpectedMaterialInformation) *stateStub {
+	stub := &stateStub{state: map[string][]byte{}}
+	for _, expectedMaterialInformation := range expectedMaterials {
+		if err := putExpectedMaterialInformation(stub, expectedMaterialInformation); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stub
+}
+
+func TestNewMaterialReceipts(t *testing.T) {
+	tests := []struct {
+		name           string
+		ordered        string
+		receipts       []ActualMaterialInformation
+		wantReceived   string
+		wantComplete   bool
+		wantActualDate string
+	}{
+		{"nothing received", "10", []ActualMaterialInformation{}, "0", false, ""},
+		{"partially received", "10", []ActualMaterialInformation{{ActualDate: "01/10/2020", ReceivedQuantity: "4"}}, "4", false, ""},
+		{"received in several receipts", "10", []ActualMaterialInformation{
+			{ActualDate: "01/20/2020", ReceivedQuantity: "4"},
+			{ActualDate: "01/10/2020", ReceivedQuantity: "6"},
+		}, "10", true, "01/20/2020"},
+		{"received more than ordered", "10", []ActualMaterialInformation{{ActualDate: "01/10/2020", ReceivedQuantity: "12"}}, "10", true, "01/10/2020"},
+		{"receipt without quantity delivers the rest", "10", []ActualMaterialInformation{
+			{ActualDate: "01/10/2020", ReceivedQuantity: "4"},
+			{ActualDate: "01/15/2020"},
+		}, "10", true, "01/15/2020"},
+		{"decimal quantities", "2.5", []ActualMaterialInformation{
+			{ActualDate: "01/10/2020", ReceivedQuantity: "1.25"},
+			{ActualDate: "01/12/2020", ReceivedQuantity: "1.25"},
+		}, "2.5", true, "01/12/2020"},
+		{"without ordered quantity", "", []ActualMaterialInformation{{ActualDate: "01/10/2020"}}, "1", true, "01/10/2020"},
+	}
+
+	for _, test := range tests {
+		materialReceipts := newMaterialReceipts(ExpectedMaterialInformation{OrderedQuantity: test.ordered}, test.receipts)
+		if got := quantityString(materialReceipts.received); got != test.wantReceived {
+			t.Errorf("%s: received = %s, want %s", test.name, got, test.wantReceived)
+		}
+		if materialReceipts.isComplete != test.wantComplete {
+			t.Errorf("%s: complete = %v, want %v", test.name, materialReceipts.isComplete, test.wantComplete)
+		}
+		if got := materialReceipts.actualDate(); got != test.wantActualDate {
+			t.Errorf("%s: actual date = %q, want %q", test.name, got, test.wantActualDate)
+		}
+	}
+}
+
+func TestQuantityString(t *testing.T) {
+	tests := []struct {
+		quantity *big.Rat
+		want     string
+	}{
+		{big.NewRat(10, 1), "10"},
+		{big.NewRat(5, 2), "2.5"},
+		{big.NewRat(1, 8), "0.125"},
+		{big.NewRat(-3, 4), "-0.75"},
+		{new(big.Rat), "0"},
+	}
+
+	for _, test := range tests {
+		if got := quantityString(test.quantity); got != test.want {
+			t.Errorf("quantityString(%s) = %s, want %s", test.quantity.RatString(), got, test.want)
+		}
+	}
+}
+
+func TestParsePurchaseOrderLines(t *testing.T) {
+	tests := []struct {
+		name            string
+		linesInJson     string
+		wantLineNumbers string
+		wantErr         string
+	}{
+		{"numbered in order", `[{"materialNumber":"M1","quantity":"10"},{"materialNumber":"M2","quantity":"2.5","unitPrice":"12.50","requestedDate":"03/31/2020"}]`, "1 2", ""},
+		{"own line numbers", `[{"lineNumber":"10","materialNumber":"M1","quantity":"10"},{"materialNumber":"M2","quantity":"1"}]`, "10 2", ""},
+		{"material missing", `[{"quantity":"10"}]`, "", "line 1: materialNumber is mandatory"},
+		{"material on two lines", `[{"materialNumber":"M1","quantity":"10"},{"materialNumber":"M1","quantity":"5"}]`, "", "line 2: material M1 is ordered on another line"},
+		{"quantity zero", `[{"materialNumber":"M1","quantity":"0"}]`, "", "line 1: quantity must be a decimal number greater than 0"},
+		{"quantity not a decimal", `[{"materialNumber":"M1","quantity":"1e3"}]`, "", "line 1: quantity must be a decimal number greater than 0"},
+		{"negative unit price", `[{"materialNumber":"M1","quantity":"10","unitPrice":"-1"}]`, "", "line 1: unitPrice must be a decimal number greater than 0"},
+		{"requested date format", `[{"materialNumber":"M1","quantity":"10","requestedDate":"2020-03-31"}]`, "", "line 1: requestedDate must be in format 01/02/2006"},
+	}
+
+	for _, test := range tests {
+		lines, err := parsePurchaseOrderLines(test.linesInJson)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%s: error = %v, want %s", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		lineNumbers := []string{}
+		for _, line := range lines {
+			lineNumbers = append(lineNumbers, line.LineNumber)
+		}
+		if got := strings.Join(lineNumbers, " "); got != test.wantLineNumbers {
+			t.Errorf("%s: line numbers = %q, want %q", test.name, got, test.wantLineNumbers)
+		}
+	}
+}
+
+func TestParseChangeOrderChanges(t *testing.T) {
+	stub := newStateStub(t, ExpectedMaterialInformation{MaterialNumber: "M2", Ex_PurchaseOrderNumber: "PO1"})
+	purchaseOrderObject := PurchaseOrder{PurchaseOrderNumber: "PO1", Lines: []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10"}}}
+
+	tests := []struct {
+		name          string
+		changesInJson string
+		wantErr       string
+	}{
+		{"quantity of a line", `[{"materialNumber":"M1","quantity":"12"}]`, ""},
+		{"material without line", `[{"materialNumber":"M2","requestedDate":"03/31/2020"}]`, ""},
+		{"no changes", `[]`, "at least one change is mandatory"},
+		{"nothing changed", `[{"materialNumber":"M1"}]`, "material M1: quantity or requestedDate is mandatory"},
+		{"quantity zero", `[{"materialNumber":"M1","quantity":"0"}]`, "material M1: quantity must be a decimal number greater than 0"},
+		{"requested date format", `[{"materialNumber":"M1","requestedDate":"31.03.2020"}]`, "material M1: requestedDate must be in format 01/02/2006"},
+		{"material not ordered", `[{"materialNumber":"M3","quantity":"1"}]`, "material M3 is not ordered on purchase order PO1"},
+	}
+
+	for _, test := range tests {
+		_, err := parseChangeOrderChanges(stub, purchaseOrderObject, test.changesInJson)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.wantErr {
+			t.Errorf("%s: error = %q, want %q", test.name, got, test.wantErr)
+		}
+	}
+}
+
+func TestApplyChange(t *testing.T) {
+	changeOrder := ChangeOrder{ChangeOrderNumber: "CO1", Reason: "demand moved", MspId: "BuyerMSP"}
+
+	tests := []struct {
+		name              string
+		expected          ExpectedMaterialInformation
+		change            PurchaseOrderLine
+		wantQuantity      string
+		wantExpectedDate  string
+		wantDateStatus    string
+		wantReschedules   string
+		wantLineQuantity  string
+		wantRequestedDate string
+	}{
+		{
+			name:             "quantity",
+			expected:         ExpectedMaterialInformation{ExpectedDate: "03/01/2020", OrderedQuantity: "10"},
+			change:           PurchaseOrderLine{Quantity: "12"},
+			wantQuantity:     "12",
+			wantExpectedDate: "03/01/2020",
+			wantDateStatus:   dateStatusConfirmed,
+			wantLineQuantity: "12",
+		},
+		{
+			name:              "confirmed date is rescheduled by the buyer",
+			expected:          ExpectedMaterialInformation{ExpectedDate: "03/01/2020", OrderedQuantity: "10", DateStatus: dateStatusConfirmed, PendingReschedule: &RescheduleEvent{NewExpectedDate: "03/20/2020"}},
+			change:            PurchaseOrderLine{RequestedDate: "03/15/2020"},
+			wantQuantity:      "10",
+			wantExpectedDate:  "03/15/2020",
+			wantDateStatus:    dateStatusConfirmed,
+			wantReschedules:   "03/01/2020 > 03/15/2020 by BUYER BuyerMSP on 02/01/2020: Change order CO1: demand moved",
+			wantLineQuantity:  "10",
+			wantRequestedDate: "03/15/2020",
+		},
+		{
+			name:              "proposed date is confirmed",
+			expected:          ExpectedMaterialInformation{ProposedDate: "03/01/2020", OrderedQuantity: "10", DateStatus: dateStatusProposed},
+			change:            PurchaseOrderLine{RequestedDate: "03/15/2020"},
+			wantQuantity:      "10",
+			wantExpectedDate:  "03/15/2020",
+			wantDateStatus:    dateStatusConfirmed,
+			wantLineQuantity:  "10",
+			wantRequestedDate: "03/15/2020",
+		},
+		{
+			name:              "same date",
+			expected:          ExpectedMaterialInformation{ExpectedDate: "03/01/2020", OrderedQuantity: "10"},
+			change:            PurchaseOrderLine{RequestedDate: "03/01/2020"},
+			wantQuantity:      "10",
+			wantExpectedDate:  "03/01/2020",
+			wantDateStatus:    dateStatusConfirmed,
+			wantLineQuantity:  "10",
+			wantRequestedDate: "03/01/2020",
+		},
+	}
+
+	for _, test := range tests {
+		test.expected.MaterialNumber, test.expected.Ex_PurchaseOrderNumber = "M1", "PO1"
+		test.change.MaterialNumber = "M1"
+		stub := newStateStub(t, test.expected)
+		purchaseOrderObject := PurchaseOrder{PurchaseOrderNumber: "PO1", Lines: []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10"}}}
+
+		if err := applyChange(stub, &purchaseOrderObject, changeOrder, test.change, "02/01/2020"); err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		var expectedMaterialInformation ExpectedMaterialInformation
+		json.Unmarshal(stub.state["Ex-M1-PO1"], &expectedMaterialInformation)
+		if expectedMaterialInformation.OrderedQuantity != test.wantQuantity {
+			t.Errorf("%s: ordered quantity = %s, want %s", test.name, expectedMaterialInformation.OrderedQuantity, test.wantQuantity)
+		}
+		if expectedMaterialInformation.ExpectedDate != test.wantExpectedDate {
+			t.Errorf("%s: expected date = %s, want %s", test.name, expectedMaterialInformation.ExpectedDate, test.wantExpectedDate)
+		}
+		if got := expectedMaterialInformation.dateStatus(); got != test.wantDateStatus {
+			t.Errorf("%s: date status = %s, want %s", test.name, got, test.wantDateStatus)
+		}
+		reschedules := []string{}
+		for _, reschedule := range expectedMaterialInformation.Reschedules {
+			reschedules = append(reschedules, fmt.Sprintf("%s > %s by %s %s on %s: %s", reschedule.OldExpectedDate, reschedule.NewExpectedDate, reschedule.RequestedBy, reschedule.MspId, reschedule.RescheduledOn, reschedule.Reason))
+		}
+		if got := strings.Join(reschedules, ", "); got != test.wantReschedules {
+			t.Errorf("%s: reschedules = %q, want %q", test.name, got, test.wantReschedules)
+		}
+		if expectedMaterialInformation.PendingReschedule != nil && test.wantReschedules != "" {
+			t.Errorf("%s: pending reschedule is kept", test.name)
+		}
+		if line := purchaseOrderObject.Lines[0]; line.Quantity != test.wantLineQuantity || line.RequestedDate != test.wantRequestedDate {
+			t.Errorf("%s: line = %s %q, want %s %q", test.name, line.Quantity, line.RequestedDate, test.wantLineQuantity, test.wantRequestedDate)
+		}
+	}
+}
+
+// blanket purchase order of the tests
+var testBlanketOrder = BlanketPurchaseOrder{
+	BlanketOrderNumber: "BPO1",
+	ValidFrom:          "01/01/2020",
+	ValidTo:            "12/31/2020",
+	TotalValue:         "1500",
+	Lines: []PurchaseOrderLine{
+		{LineNumber: "1", MaterialNumber: "M1", Quantity: "100", UnitOfMeasure: "PC", UnitPrice: "10"},
+		{LineNumber: "2", MaterialNumber: "M2", Quantity: "50", UnitOfMeasure: "KG", UnitPrice: "2.40"},
+	},
+}
+
+func TestBlanketOrderIsValidOn(t *testing.T) {
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"12/31/2019", false},
+		{"01/01/2020", true},
+		{"06/15/2020", true},
+		{"12/31/2020", true},
+		{"01/01/2021", false},
+		{"2020-06-15", false},
+	}
+
+	for _, test := range tests {
+		if got := testBlanketOrder.isValidOn(test.date); got != test.want {
+			t.Errorf("isValidOn(%s) = %v, want %v", test.date, got, test.want)
+		}
+	}
+}
+
+func TestValidateReleaseLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		lines         []PurchaseOrderLine
+		wantErr       string
+		wantUnitPrice string
+	}{
+		{"agreed terms taken over", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10"}}, "", "10"},
+		{"same unit price", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10", UnitOfMeasure: "PC", UnitPrice: "10.00", RequestedDate: "03/31/2020"}}, "", "10.00"},
+		{"no lines", []PurchaseOrderLine{}, "at least one line is mandatory", ""},
+		{"material not agreed", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M3", Quantity: "10"}}, "line 1: material M3 is not agreed on blanket purchase order BPO1", ""},
+		{"other unit of measure", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10", UnitOfMeasure: "BOX"}}, "line 1: unitOfMeasure differs from the agreed unit of measure PC", ""},
+		{"other unit price", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10", UnitPrice: "9.50"}}, "line 1: unitPrice differs from the agreed unit price 10", ""},
+		{"requested after validity", []PurchaseOrderLine{{LineNumber: "1", MaterialNumber: "M1", Quantity: "10", RequestedDate: "01/15/2021"}}, "line 1: requestedDate is not within the validity period 01/01/2020 - 12/31/2020", ""},
+	}
+
+	for _, test := range tests {
+		err := testBlanketOrder.validateReleaseLines(test.lines)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.wantErr {
+			t.Errorf("%s: error = %q, want %q", test.name, got, test.wantErr)
+		}
+		if err == nil && test.lines[0].UnitPrice != test.wantUnitPrice {
+			t.Errorf("%s: unit price = %s, want %s", test.name, test.lines[0].UnitPrice, test.wantUnitPrice)
+		}
+	}
+}
+
+func TestBlanketReleasesCheckBalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		released   []PurchaseOrderLine
+		totalValue string
+		lines      []PurchaseOrderLine
+		wantErr    string
+	}{
+		{"within balance", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "40", UnitPrice: "10"}}, "1500", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "60", UnitPrice: "10"}}, ""},
+		{"quantity exceeded", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "40", UnitPrice: "10"}}, "", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "60.5", UnitPrice: "10"}}, "material M1 exceeds the remaining quantity 60 of blanket purchase order BPO1"},
+		{"released by other material", []PurchaseOrderLine{{MaterialNumber: "M2", Quantity: "50", UnitPrice: "2.40"}}, "", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "100", UnitPrice: "10"}}, ""},
+		{"value exceeded", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "60", UnitPrice: "10"}}, "950", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "40", UnitPrice: "10"}}, "release value 400 exceeds the remaining value 350 of blanket purchase order BPO1"},
+		{"value not agreed", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "60", UnitPrice: "10"}}, "", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "40", UnitPrice: "10"}}, ""},
+		{"value of decimal quantities", []PurchaseOrderLine{{MaterialNumber: "M1", Quantity: "100", UnitPrice: "10"}}, "1001", []PurchaseOrderLine{{MaterialNumber: "M2", Quantity: "0.5", UnitPrice: "2.40"}}, "release value 1.2 exceeds the remaining value 1 of blanket purchase order BPO1"},
+	}
+
+	for _, test := range tests {
+		blanketOrderObject := testBlanketOrder
+		blanketOrderObject.TotalValue = test.totalValue
+
+		blanketReleases := blanketReleases{released: map[string]*big.Rat{}, releasedValue: new(big.Rat)}
+		blanketReleases.add(test.released)
+		err := blanketReleases.checkBalance(blanketOrderObject, test.lines)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.wantErr {
+			t.Errorf("%s: error = %q, want %q", test.name, got, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Calendar holds the non working days of a supplier location or plant. Weekends are weekday names
+// e.g. "Saturday", holidays are dates. Like penalty schedules, calendars are never changed in place, every update is
+// stored as a new version, so that delays already counted with a version do not change.
+type Calendar struct {
+	CalendarId       string   `json:"calendarId"`
+	Version          int      `json:"version"`
+	Weekends         []string `json:"weekends"`
+	Holidays         []string `json:"holidays"`
+	IsCalendarObject bool     `json:"isCalendarObject"`
+}
+
+// calendar used for business day counting when a contract does not name one
+var defaultCalendar = Calendar{
+	Weekends: []string{time.Saturday.String(), time.Sunday.String()},
+}
+
+/*
+ * Check if the given date is neither a weekend day nor a holiday.
+ */
+func (calendar Calendar) isBusinessDay(date time.Time) bool {
+	for _, weekend := range calendar.Weekends {
+		if date.Weekday().String() == weekend {
+			return false
+		}
+	}
+
+	dateInStr := date.Format(timeFormat)
+	for _, holiday := range calendar.Holidays {
+		if holiday == dateInStr {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+ * Count delay in days between expected and actual date, negative when delivered early. Without calendar every day
+ * counts, otherwise only the business days after the earlier date up to and including the later date are counted.
+ */
+func countDelayDays(expectedDate time.Time, actualDate time.Time, calendar *Calendar) float64 {
+	if calendar == nil {
+		return (actualDate.Sub(expectedDate).Hours()) / 24
+	}
+
+	from, to, sign := expectedDate, actualDate, float64(1)
+	if actualDate.Before(expectedDate) {
+		from, to, sign = actualDate, expectedDate, float64(-1)
+	}
+
+	days := float64(0)
+	for date := from.AddDate(0, 0, 1); !date.After(to); date = date.AddDate(0, 0, 1) {
+		if calendar.isBusinessDay(date) {
+			days++
+		}
+	}
+
+	return sign * days
+}
+
+/*
+ * Validate weekday names and holiday dates of a calendar.
+ */
+func validateCalendar(calendar Calendar) error {
+	if calendar.CalendarId == "" {
+		return fmt.Errorf("calendarId is mandatory")
+	}
+
+	for _, weekend := range calendar.Weekends {
+		isWeekday := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if day.String() == weekend {
+				isWeekday = true
+			}
+		}
+		if !isWeekday {
+			return fmt.Errorf("%s is not a weekday", weekend)
+		}
+	}
+
+	if len(calendar.Weekends) == 7 {
+		return fmt.Errorf("calendar must have at least one working weekday")
+	}
+
+	for _, holiday := range calendar.Holidays {
+		if _, err := time.Parse(timeFormat, holiday); err != nil {
+			return fmt.Errorf("holiday %s must be in format %s", holiday, timeFormat)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Read calendar from blockchain, returns nil calendar when it does not exist.
+ */
+func getCalendarById(stub shim.ChaincodeStubInterface, calendarId string) (*Calendar, error) {
+	calendarInBytes, err := stub.GetState("CAL-" + calendarId)
+	if err != nil {
+		return nil, err
+	}
+
+	if calendarInBytes == nil {
+		return nil, nil
+	}
+
+	var calendar Calendar
+	if err := json.Unmarshal(calendarInBytes, &calendar); err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
+
+/*
+ * Get the version of the calendar, calendars created before versions were introduced are in their first version.
+ */
+func (calendar Calendar) currentVersion() int {
+	if calendar.Version == 0 {
+		return 1
+	}
+	return calendar.Version
+}
+
+/*
+ * Read a version of a calendar from blockchain, the latest version when version is 0. Returns nil calendar when the
+ * calendar or the version does not exist.
+ */
+func getCalendarVersion(stub shim.ChaincodeStubInterface, calendarId string, version int) (*Calendar, error) {
+	calendar, err := getCalendarById(stub, calendarId)
+	if err != nil || calendar == nil || version == 0 || calendar.currentVersion() == version {
+		return calendar, err
+	}
+
+	calendarInBytes, err := stub.GetState("CALV-" + calendarId + "-" + strconv.Itoa(version))
+	if err != nil || calendarInBytes == nil {
+		return nil, err
+	}
+
+	var calendarVersion Calendar
+	if err := json.Unmarshal(calendarInBytes, &calendarVersion); err != nil {
+		return nil, err
+	}
+
+	return &calendarVersion, nil
+}
+
+/*
+ * Validate and write calendar to blockchain, as latest version and under its version number.
+ */
+func putCalendar(stub shim.ChaincodeStubInterface, calendar Calendar) peer.Response {
+	if err := validateCalendar(calendar); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid calendar: "+err.Error())
+	}
+
+	calendar.Version = calendar.currentVersion()
+	calendar.IsCalendarObject = true
+
+	// convert to byte
+	calendarInBytes, _ := json.Marshal(calendar)
+
+	// write calendar to BC
+	if err := stub.PutState("CAL-"+calendar.CalendarId, calendarInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := stub.PutState("CALV-"+calendar.CalendarId+"-"+strconv.Itoa(calendar.Version), calendarInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", calendarInBytes)
+}
+
+/*
+ * Function for a buyer organization to create calendar of a supplier location or plant.
+ * 1st - calendar as json e.g. {"calendarId":"PLANT-1000","weekends":["Saturday","Sunday"],"holidays":["12/25/2019"]}
+ */
+func (cc *Invoice) createCalendar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain calendars"); response.Status != http.StatusOK {
+		return response
+	}
+
+	var calendar Calendar
+	if err := json.Unmarshal([]byte(args[0]), &calendar); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid calendar: "+err.Error())
+	}
+	calendar.Version = 1
+
+	// Check if calendar already exists
+	if existing, err := getCalendarById(stub, calendar.CalendarId); err != nil || existing != nil {
+		return Error(http.StatusConflict, "Calendar "+calendar.CalendarId+" already exists")
+	}
+
+	if response := putCalendar(stub, calendar); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Calendar Created Successsfully!", nil)
+}
+
+/*
+ * Function for a buyer organization to publish new weekends and holidays of an existing calendar as its next version.
+ * Contracts keep the version they were agreed on.
+ * 1st - calendar as json
+ */
+func (cc *Invoice) updateCalendar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain calendars"); response.Status != http.StatusOK {
+		return response
+	}
+
+	var calendar Calendar
+	if err := json.Unmarshal([]byte(args[0]), &calendar); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid calendar: "+err.Error())
+	}
+
+	existing, err := getCalendarById(stub, calendar.CalendarId)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if existing == nil {
+		return Error(http.StatusNotFound, "Calendar "+calendar.CalendarId+" not found")
+	}
+
+	// calendars created before versions were introduced are kept as their first version
+	if existing.Version == 0 {
+		if response := putCalendar(stub, *existing); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	calendar.Version = existing.currentVersion() + 1
+
+	return putCalendar(stub, calendar)
+}
+
+/*
+ * Function to get calendar by id.
+ * 1st - calendar id
+ * optional: 2nd - version, the latest version if not passed
+ */
+func (cc *Invoice) getCalendar(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	version := 0
+	if versionArg := optionalArg(args, 1); versionArg != "" {
+		var err error
+		if version, err = strconv.Atoi(versionArg); err != nil || version <= 0 {
+			return Error(http.StatusNotAcceptable, "Version must be a number greater than 0")
+		}
+	}
+
+	calendar, err := getCalendarVersion(stub, args[0], version)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if calendar == nil {
+		return Error(http.StatusNotFound, "Calendar "+args[0]+" not found")
+	}
+
+	calendarInBytes, _ := json.Marshal(calendar)
+	return Success(http.StatusOK, "OK", calendarInBytes)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountDelayDays(t *testing.T) {
+	weekends := []string{time.Saturday.String(), time.Sunday.String()}
+
+	tests := []struct {
+		name         string
+		expectedDate string
+		actualDate   string
+		calendar     *Calendar
+		want         float64
+	}{
+		{"every day counts", "01/01/2020", "01/11/2020", nil, 10},
+		{"every day counts early", "01/11/2020", "01/01/2020", nil, -10},
+		{"on time", "01/10/2020", "01/10/2020", nil, 0},
+		{"friday to monday", "01/03/2020", "01/06/2020", &Calendar{Weekends: weekends}, 1},
+		{"monday to friday early", "01/06/2020", "01/03/2020", &Calendar{Weekends: weekends}, -1},
+		{"over a weekend", "01/03/2020", "01/13/2020", &Calendar{Weekends: weekends}, 6},
+		{"delivered on a saturday", "01/03/2020", "01/04/2020", &Calendar{Weekends: weekends}, 0},
+		{"over a holiday", "01/06/2020", "01/08/2020", &Calendar{Weekends: weekends, Holidays: []string{"01/07/2020"}}, 1},
+		{"on time with calendar", "01/06/2020", "01/06/2020", &Calendar{Weekends: weekends}, 0},
+	}
+
+	for _, test := range tests {
+		expectedDate, _ := time.Parse(timeFormat, test.expectedDate)
+		actualDate, _ := time.Parse(timeFormat, test.actualDate)
+
+		if got := countDelayDays(expectedDate, actualDate, test.calendar); got != test.want {
+			t.Errorf("%s: countDelayDays(%s, %s) = %v, want %v", test.name, test.expectedDate, test.actualDate, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// ChaincodeConfig is passed as json to Init on instantiate or upgrade, e.g.
+// {"baseCurrency":"EUR","fxRateMspIds":["BuyerMSP"],"buyerMspIds":["BuyerMSP"],"demandChaincodeName":"demand",
+// "priceTolerancePercent":2,"quantityTolerancePercent":0}
+// Invoices are matched against purchase orders and goods receipts of the demand chaincode, the tolerances are
+// the variances accepted above the purchase order's price and the received quantity.
+type ChaincodeConfig struct {
+	BaseCurrency             string   `json:"baseCurrency"`
+	FxRateMspIds             []string `json:"fxRateMspIds"`
+	BuyerMspIds              []string `json:"buyerMspIds"`
+	DemandChaincodeName      string   `json:"demandChaincodeName"`
+	PriceTolerancePercent    float64  `json:"priceTolerancePercent"`
+	QuantityTolerancePercent float64  `json:"quantityTolerancePercent"`
+}
+
+/*
+ * Read chaincode config from blockchain, empty config if Init was never called with one.
+ */
+func getChaincodeConfig(stub shim.ChaincodeStubInterface) (ChaincodeConfig, error) {
+	var config ChaincodeConfig
+
+	configInBytes, err := stub.GetState("CONFIG")
+	if err != nil || configInBytes == nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(configInBytes, &config)
+	return config, err
+}
+
+/*
+ * Validate and write chaincode config passed to Init.
+ */
+func putChaincodeConfig(stub shim.ChaincodeStubInterface, configInJson string) peer.Response {
+	var config ChaincodeConfig
+	if err := json.Unmarshal([]byte(configInJson), &config); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid config: "+err.Error())
+	}
+
+	if config.BaseCurrency != "" && !currencyPattern.MatchString(config.BaseCurrency) {
+		return Error(http.StatusNotAcceptable, "Invalid config: baseCurrency must be an ISO 4217 code")
+	}
+
+	if config.PriceTolerancePercent < 0 || config.QuantityTolerancePercent < 0 {
+		return Error(http.StatusNotAcceptable, "Invalid config: tolerances must not be negative")
+	}
+
+	// convert to byte
+	configInBytes, _ := json.Marshal(config)
+
+	// write config to BC
+	if err := stub.PutState("CONFIG", configInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", configInBytes)
+}
+
+/*
+ * Get MSP id of the organization of the client which submitted the transaction.
+ */
+func getCallerMspId(stub shim.ChaincodeStubInterface) (string, error) {
+	return cid.GetMSPID(stub)
+}
+
+/*
+ * Check that the client which submitted the transaction belongs to one of the given organizations.
+ */
+func checkCallerMsp(stub shim.ChaincodeStubInterface, mspIds []string) error {
+	callerMspId, err := getCallerMspId(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, mspId := range mspIds {
+		if mspId == callerMspId {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("organization %s is not authorized", callerMspId)
+}
+
+/*
+ * Check that the client which submitted the transaction belongs to a buyer organization. Only buyers maintain
+ * master data like penalty schedules, contracts, calendars and delay reasons.
+ */
+func checkCallerIsBuyer(stub shim.ChaincodeStubInterface, action string) peer.Response {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := checkCallerMsp(stub, config.BuyerMspIds); err != nil {
+		return Error(http.StatusForbidden, "Not allowed to "+action+": "+err.Error())
+	}
+	return Success(http.StatusOK, "OK", nil)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// states of a credit note
+const (
+	creditNoteStatusIssued  = "ISSUED"
+	creditNoteStatusSettled = "SETTLED"
+)
+
+// CreditNote is the document accounts payable deducts a final delay penalty with from the payment of the invoices
+// of a material. There is at most one credit note per material of a purchase order, its number is derived from them.
+// The amount is allocated across the invoices of the material, InvoiceId is the key of the first of them. An issued
+// credit note is adjusted when a dispute of its penalty is upheld or a waiver of it is approved, PreviousAmount is the
+// amount before.
+type CreditNote struct {
+	CreditNoteNumber       string              `json:"creditNoteNumber"`
+	InvoiceId              string              `json:"invoiceId"`
+	Cn_MaterialNumber      string              `json:"cn_MaterialNumber"`
+	Cn_PurchaseOrderNumber string              `json:"cn_PurchaseOrderNumber"`
+	Amount                 Money               `json:"amount"`
+	Allocations            []PenaltyAllocation `json:"allocations"`
+	Currency               string              `json:"currency"`
+	Reason                 string              `json:"reason"`
+	Status                 string              `json:"status"`
+	IssuedOn               string              `json:"issuedOn"`
+	SettledOn              string              `json:"settledOn"`
+	SettlementReference    string              `json:"settlementReference"`
+	PreviousAmount         *Money              `json:"previousAmount,omitempty"`
+	AdjustedOn             string              `json:"adjustedOn"`
+	IsCreditNoteObject     bool                `json:"isCreditNoteObject"`
+}
+
+/*
+ * Get the part of the credit note deducted from an invoice, credit notes issued before allocations were introduced
+ * are deducted from the invoice they were issued for.
+ */
+func (creditNote *CreditNote) amountFor(invoice Invoice) Money {
+	if creditNote == nil {
+		return Money{}
+	}
+	if len(creditNote.Allocations) == 0 {
+		if creditNote.InvoiceId == "IN-"+invoice.InvoiceNumber {
+			return creditNote.Amount
+		}
+		return Money{}
+	}
+	return allocatedAmount(creditNote.Allocations, invoice.InvoiceNumber)
+}
+
+/*
+ * Read credit note of the invoice of a material of a purchase order from blockchain, returns nil credit note when none was issued.
+ */
+func getCreditNoteById(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) (*CreditNote, error) {
+	creditNoteInBytes, err := stub.GetState("CN-" + materialNumber + "-" + purchaseOrderNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if creditNoteInBytes == nil {
+		return nil, nil
+	}
+
+	var creditNote CreditNote
+	if err := json.Unmarshal(creditNoteInBytes, &creditNote); err != nil {
+		return nil, err
+	}
+
+	return &creditNote, nil
+}
+
+/*
+ * Write credit note to blockchain.
+ */
+func putCreditNote(stub shim.ChaincodeStubInterface, creditNote CreditNote) error {
+	creditNote.IsCreditNoteObject = true
+
+	// convert to byte
+	creditNoteInBytes, _ := json.Marshal(creditNote)
+
+	// write credit note to BC
+	return stub.PutState(creditNote.CreditNoteNumber, creditNoteInBytes)
+}
+
+/*
+ * Function to issue the credit note for the delay penalty of a late delivery. It is called through issueCreditNote of
+ * the demand chaincode once a goods receipt is recorded, or again once a dispute or waiver blocking it was decided. No
+ * credit note is issued as long as the penalty is not final or when there is no penalty to deduct.
+ * 1st - purchase order #, 2nd - material number, 3rd - material as json with supplier code, expected date, goods
+ * receipts with their delay reasons and reschedules, as returned by getPurchaseOrderMaterial of the demand chaincode
+ */
+func (cc *Invoice) issueCreditNote(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	// the demand chaincode calls this function, so the material is passed instead of being read from it
+	material, err := parsePurchaseOrderMaterial(args[2], args[1], args[0])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid material: "+err.Error())
+	}
+	if _, err := getCallerPartyForSupplier(stub, material.SupplierCode); err != nil {
+		return Error(http.StatusForbidden, "Not allowed to issue credit notes: "+err.Error())
+	}
+
+	deliveries, err := material.deliveries()
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid deliveries: "+err.Error())
+	}
+
+	invoices, err := getInvoicesForDelivery(stub, args[1], args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if len(invoices) == 0 {
+		return Success(http.StatusOK, "Material is not invoiced yet, no credit note issued", nil)
+	}
+	if len(activeInvoices(invoices)) == 0 {
+		return Success(http.StatusOK, "Invoices are cancelled, no credit note issued", nil)
+	}
+
+	// Check if credit note was issued already
+	if creditNote, err := getCreditNoteById(stub, args[1], args[0]); err != nil || creditNote != nil {
+		return Error(http.StatusConflict, "Credit note for purchase order "+args[0]+" and material number "+args[1]+" already exists")
+	}
+
+	// penalty is not final as long as it is disputed or a waiver is pending
+	dispute, err := getPenaltyDisputeById(stub, args[1], args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if dispute.isOpen() {
+		return Success(http.StatusOK, "Penalty is disputed, no credit note issued until the dispute is resolved", nil)
+	}
+
+	waiver, err := getPenaltyWaiverById(stub, args[1], args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if waiver != nil && waiver.Status == waiverStatusRequested {
+		return Success(http.StatusOK, "Penalty waiver is pending, no credit note issued until it is approved or rejected", nil)
+	}
+
+	txDate, err := getEvaluationDate(stub, "")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	evaluation, terms, err := evaluateFinalPenalty(stub, invoices, *material, deliveries, dispute, waiver, txDate)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if !evaluation.isLateDelivery || evaluation.penalty.sign() <= 0 {
+		return Success(http.StatusOK, "No penalty to credit, no credit note issued", nil)
+	}
+
+	// penalty is deducted from the invoices of the material in proportion to their net amounts
+	allocations := allocatePenalty(evaluation.penalty, activeInvoices(invoices), terms.roundingMode())
+
+	creditNote := CreditNote{
+		CreditNoteNumber:       "CN-" + args[1] + "-" + args[0],
+		InvoiceId:              "IN-" + allocations[0].InvoiceNumber,
+		Cn_MaterialNumber:      args[1],
+		Cn_PurchaseOrderNumber: args[0],
+		Amount:                 evaluation.penalty,
+		Allocations:            allocations,
+		Currency:               invoices[0].Currency,
+		Reason:                 creditNoteReason(evaluation, waiver),
+		Status:                 creditNoteStatusIssued,
+		IssuedOn:               txDate.Format(timeFormat),
+	}
+
+	if err := putCreditNote(stub, creditNote); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	creditNoteInBytes, _ := json.Marshal(creditNote)
+	return Success(http.StatusCreated, "Credit Note Issued Successsfully!", creditNoteInBytes)
+}
+
+/*
+ * Evaluate the final penalty of the invoices of a material on its expected date, receipts and reschedules, corrected
+ * by an upheld dispute and reduced by an approved waiver.
+ */
+func evaluateFinalPenalty(stub shim.ChaincodeStubInterface, invoices []Invoice, material PurchaseOrderMaterial, deliveries *MaterialDeliveries, dispute *PenaltyDispute, waiver *PenaltyWaiver, asOfDate time.Time) (delayEvaluation, PenaltyTerms, error) {
+	// invoices created before suppliers were kept on them are of the supplier of the purchase order
+	supplierCode := invoices[0].SupplierCode
+	if supplierCode == "" {
+		supplierCode = material.SupplierCode
+	}
+
+	terms, baselineDate, err := resolveBaselinePenaltyTerms(stub, supplierCode, material)
+	if err != nil {
+		return delayEvaluation{}, terms, err
+	}
+
+	evaluationDate, actualDate := dispute.evaluationDates(asOfDate, material.ActualDate)
+
+	if deliveries != nil {
+		correctedDeliveries := dispute.correctDeliveries(*deliveries)
+		deliveries = &correctedDeliveries
+	}
+
+	return evaluateInvoices(invoices, terms, waiver, evaluationDate, baselineDate, actualDate, material.DelayReason, deliveries), terms, nil
+}
+
+/*
+ * Describe the penalty a credit note deducts.
+ */
+func creditNoteReason(evaluation delayEvaluation, waiver *PenaltyWaiver) string {
+	reason := "Delay penalty " + evaluation.status
+	if evaluation.exemption != nil {
+		reason = reason + ", exemption " + evaluation.exemption.ReasonCode
+	}
+	if waiver != nil && waiver.Status == waiverStatusApproved {
+		reason = reason + ", waiver approved"
+	}
+	return reason
+}
+
+/*
+ * Adjust the issued credit note of a material to the penalty evaluated again with the dispute and waiver decided in the
+ * current transaction, which does not read its own writes. Nothing is adjusted when no credit note was issued, it is
+ * issued on the changed penalty later on. A settled credit note can not be adjusted anymore.
+ */
+func adjustCreditNote(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string, dispute *PenaltyDispute, waiver *PenaltyWaiver, adjustment string) peer.Response {
+	creditNote, err := getCreditNoteById(stub, materialNumber, purchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if creditNote == nil {
+		return Success(http.StatusOK, "OK", nil)
+	}
+	if creditNote.Status != creditNoteStatusIssued {
+		return Error(http.StatusConflict, "Credit note is already "+creditNote.Status+" and can not be adjusted")
+	}
+
+	material, response := getPurchaseOrderMaterial(stub, materialNumber, purchaseOrderNumber)
+	if response.Status != http.StatusOK {
+		return response
+	}
+	deliveries, err := material.deliveries()
+	if err != nil {
+		return Error(http.StatusInternalServerError, "Invalid deliveries of demand chaincode: "+err.Error())
+	}
+
+	invoices, err := getInvoicesForDelivery(stub, materialNumber, purchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if len(activeInvoices(invoices)) == 0 {
+		return Error(http.StatusConflict, "Invoices are cancelled, credit note can not be adjusted")
+	}
+
+	txDate, err := getEvaluationDate(stub, "")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	evaluation, terms, err := evaluateFinalPenalty(stub, invoices, *material, deliveries, dispute, waiver, txDate)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// the claimed delivery date may leave no penalty at all
+	penalty := evaluation.penalty
+	if !evaluation.isLateDelivery || penalty.sign() < 0 {
+		penalty = Money{}
+	}
+
+	previousAmount := creditNote.Amount
+	creditNote.PreviousAmount = &previousAmount
+	creditNote.Amount = penalty
+	creditNote.Allocations = allocatePenalty(penalty, activeInvoices(invoices), terms.roundingMode())
+	creditNote.Reason = creditNoteReason(evaluation, waiver) + ", adjusted to " + adjustment
+	creditNote.AdjustedOn = txDate.Format(timeFormat)
+
+	if err := putCreditNote(stub, *creditNote); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Adjust the issued credit note of a material to the penalty of an upheld dispute, which is evaluated again with the
+ * delivery date the supplier claimed.
+ */
+func adjustCreditNoteForDispute(stub shim.ChaincodeStubInterface, dispute PenaltyDispute) peer.Response {
+	waiver, err := getPenaltyWaiverById(stub, dispute.Ds_MaterialNumber, dispute.Ds_PurchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return adjustCreditNote(stub, dispute.Ds_MaterialNumber, dispute.Ds_PurchaseOrderNumber, &dispute, waiver, "upheld dispute")
+}
+
+/*
+ * Adjust the issued credit note of a material to the penalty reduced by an approved waiver.
+ */
+func adjustCreditNoteForWaiver(stub shim.ChaincodeStubInterface, waiver PenaltyWaiver) peer.Response {
+	dispute, err := getPenaltyDisputeById(stub, waiver.Wv_MaterialNumber, waiver.Wv_PurchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return adjustCreditNote(stub, waiver.Wv_MaterialNumber, waiver.Wv_PurchaseOrderNumber, dispute, &waiver, "approved waiver")
+}
+
+/*
+ * Function to settle an issued credit note, only buyer organizations settle credit notes.
+ * 1st - material number, 2nd - purchase order #, 3rd - settlement reference e.g. payment document number
+ */
+func (cc *Invoice) settleCreditNote(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := checkCallerMsp(stub, config.BuyerMspIds); err != nil {
+		return Error(http.StatusForbidden, "Not allowed to settle credit notes: "+err.Error())
+	}
+
+	creditNote, err := getCreditNoteById(stub, args[0], args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if creditNote == nil {
+		return Error(http.StatusNotFound, "Credit note for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if creditNote.Status != creditNoteStatusIssued {
+		return Error(http.StatusConflict, "Credit note is already "+creditNote.Status)
+	}
+
+	// an upheld dispute may still reduce the credit note
+	dispute, err := getPenaltyDisputeById(stub, args[0], args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if dispute.isOpen() {
+		return Error(http.StatusConflict, "Penalty is disputed, credit note can be settled once the dispute is resolved")
+	}
+
+	// penalty is only deducted from invoices approved for payment
+	invoices, err := getInvoicesForDelivery(stub, args[0], args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	for _, invoice := range invoices {
+		if creditNote.amountFor(invoice).sign() > 0 && !invoice.isApproved() {
+			return Error(http.StatusConflict, "Credit note can only be settled against approved invoices, invoice "+invoice.InvoiceNumber+" is "+invoice.lifecycleStatus())
+		}
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	creditNote.Status = creditNoteStatusSettled
+	creditNote.SettledOn = txTime.Format(timeFormat)
+	creditNote.SettlementReference = args[2]
+
+	if err := putCreditNote(stub, *creditNote); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// invoices partially paid before the credit note was issued have nothing open anymore once it is settled
+	for _, invoice := range invoices {
+		if invoice.lifecycleStatus() == invoiceStatusPartiallyPaid && invoice.openAmount(creditNote).sign() <= 0 {
+			if response := changeInvoiceStatus(stub, invoice, invoiceStatusPaid, partyBuyer, "Credit note "+creditNote.CreditNoteNumber+" settled"); response.Status != http.StatusOK {
+				return response
+			}
+		}
+	}
+
+	return Success(http.StatusOK, "Credit Note Settled Successsfully!", nil)
+}
+
+/*
+ * Function to list credit notes.
+ * optional: 1st - purchase order #, 2nd - status e.g. ISSUED
+ */
+func (cc *Invoice) getCreditNotes(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	selector := map[string]interface{}{"isCreditNoteObject": true}
+	if purchaseOrderNumber := optionalArg(args, 0); purchaseOrderNumber != "" {
+		selector["cn_PurchaseOrderNumber"] = purchaseOrderNumber
+	}
+	if status := optionalArg(args, 1); status != "" {
+		selector["status"] = status
+	}
+	queryInBytes, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	resultsIterator, err := stub.GetQueryResult(string(queryInBytes))
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	defer resultsIterator.Close()
+
+	creditNotes := []CreditNote{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+
+		var creditNote CreditNote
+		json.Unmarshal(queryResponse.Value, &creditNote)
+		creditNotes = append(creditNotes, creditNote)
+	}
+
+	creditNotesInBytes, _ := json.Marshal(creditNotes)
+	return Success(http.StatusOK, "OK", creditNotesInBytes)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// reason codes are upper case words joined by underscores, e.g. FORCE_MAJEURE
+var reasonCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// DelayReason is an entry of the catalog of coded reasons a supplier can give for a delayed delivery.
+type DelayReason struct {
+	ReasonCode          string `json:"reasonCode"`
+	Description         string `json:"description"`
+	IsDelayReasonObject bool   `json:"isDelayReasonObject"`
+}
+
+// PenaltyExemption waives the given percentage of the penalty when the delivery was delayed for the reason,
+// 100 waives it completely.
+type PenaltyExemption struct {
+	ReasonCode    string  `json:"reasonCode"`
+	WaivedPercent float64 `json:"waivedPercent"`
+}
+
+/*
+ * Reasons written during Init so that contracts can refer to the common excusable delays out of the box.
+ */
+func defaultDelayReasons() []DelayReason {
+	return []DelayReason{
+		{ReasonCode: "FORCE_MAJEURE", Description: "Force majeure e.g. natural disaster, war or strike", IsDelayReasonObject: true},
+		{ReasonCode: "BUYER_CAUSED", Description: "Caused by the buyer e.g. late specification or change of order", IsDelayReasonObject: true},
+		{ReasonCode: "CARRIER", Description: "Caused by the carrier", IsDelayReasonObject: true},
+	}
+}
+
+/*
+ * Read delay reason from blockchain, returns nil reason when it is not in the catalog.
+ */
+func getDelayReasonByCode(stub shim.ChaincodeStubInterface, reasonCode string) (*DelayReason, error) {
+	reasonInBytes, err := stub.GetState("DR-" + reasonCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if reasonInBytes == nil {
+		return nil, nil
+	}
+
+	var reason DelayReason
+	if err := json.Unmarshal(reasonInBytes, &reason); err != nil {
+		return nil, err
+	}
+
+	return &reason, nil
+}
+
+/*
+ * Validate and write delay reason to blockchain.
+ */
+func putDelayReason(stub shim.ChaincodeStubInterface, reason DelayReason) peer.Response {
+	if !reasonCodePattern.MatchString(reason.ReasonCode) {
+		return Error(http.StatusNotAcceptable, "Invalid delay reason: reasonCode must be upper case letters, digits and underscores")
+	}
+
+	reason.IsDelayReasonObject = true
+
+	// convert to byte
+	reasonInBytes, _ := json.Marshal(reason)
+
+	// write delay reason to BC
+	if err := stub.PutState("DR-"+reason.ReasonCode, reasonInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", reasonInBytes)
+}
+
+/*
+ * Validate that exemptions refer to reasons of the catalog, once each, and waive between 0 and 100 percent.
+ */
+func validatePenaltyExemptions(stub shim.ChaincodeStubInterface, exemptions []PenaltyExemption) error {
+	seen := map[string]bool{}
+
+	for _, exemption := range exemptions {
+		if seen[exemption.ReasonCode] {
+			return fmt.Errorf("exemption for %s is given more than once", exemption.ReasonCode)
+		}
+		seen[exemption.ReasonCode] = true
+
+		if exemption.WaivedPercent <= 0 || exemption.WaivedPercent > 100 {
+			return fmt.Errorf("exemption for %s: waivedPercent must be greater than 0 and at most 100", exemption.ReasonCode)
+		}
+
+		reason, err := getDelayReasonByCode(stub, exemption.ReasonCode)
+		if err != nil {
+			return err
+		}
+		if reason == nil {
+			return fmt.Errorf("delay reason %s not found", exemption.ReasonCode)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Function for a buyer organization to add a coded delay reason to the catalog.
+ * 1st - reason code e.g. CUSTOMS, 2nd - description
+ */
+func (cc *Invoice) createDelayReason(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain delay reasons"); response.Status != http.StatusOK {
+		return response
+	}
+
+	// Check if delay reason already exists
+	if existing, err := getDelayReasonByCode(stub, args[0]); err != nil || existing != nil {
+		return Error(http.StatusConflict, "Delay reason "+args[0]+" already exists")
+	}
+
+	if response := putDelayReason(stub, DelayReason{ReasonCode: args[0], Description: args[1]}); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Delay Reason Created Successsfully!", nil)
+}
+
+/*
+ * Function to get all delay reasons of the catalog.
+ */
+func (cc *Invoice) getAllDelayReasons(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	resultsIterator, err := stub.GetQueryResult("{\"selector\":{\"isDelayReasonObject\":true}}")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	defer resultsIterator.Close()
+
+	reasons := []DelayReason{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+
+		var reason DelayReason
+		json.Unmarshal(queryResponse.Value, &reason)
+		reasons = append(reasons, reason)
+	}
+
+	reasonsInBytes, _ := json.Marshal(reasons)
+	return Success(http.StatusOK, "OK", reasonsInBytes)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// MaterialReceipt is one goods receipt of a material delivered in several parts.
+type MaterialReceipt struct {
+	ReceiptNumber    string `json:"receiptNumber"`
+	ActualDate       string `json:"actualDate"`
+	ReceivedQuantity string `json:"receivedQuantity"`
+	DelayReason      string `json:"delayReason"`
+}
+
+// MaterialDeliveries are the ordered quantity and the receipts of a material as recorded by the demand chaincode.
+// A receipt without quantity delivers the remaining quantity. UnitPrice values the receipts, without it they are
+// valued with their share of the invoice amount.
+type MaterialDeliveries struct {
+	OrderedQuantity string            `json:"orderedQuantity"`
+	UnitPrice       string            `json:"unitPrice"`
+	Receipts        []MaterialReceipt `json:"receipts"`
+}
+
+/*
+ * Validate quantities and dates of deliveries.
+ */
+func (deliveries MaterialDeliveries) validate() error {
+	if ordered, err := parseMoney(deliveries.OrderedQuantity); err != nil || ordered.sign() <= 0 {
+		return fmt.Errorf("orderedQuantity must be a positive decimal number")
+	}
+
+	if deliveries.UnitPrice != "" {
+		if _, err := parseMoney(deliveries.UnitPrice); err != nil {
+			return fmt.Errorf("unitPrice: %s", err.Error())
+		}
+	}
+
+	for _, receipt := range deliveries.Receipts {
+		if _, err := time.Parse(timeFormat, receipt.ActualDate); err != nil {
+			return fmt.Errorf("receipt %s: actualDate must be in format %s", receipt.ReceiptNumber, timeFormat)
+		}
+		if receipt.ReceivedQuantity != "" {
+			if quantity, err := parseMoney(receipt.ReceivedQuantity); err != nil || quantity.sign() <= 0 {
+				return fmt.Errorf("receipt %s: receivedQuantity must be a positive decimal number", receipt.ReceiptNumber)
+			}
+		}
+	}
+
+	return nil
+}
+
+// penalties of the parts of a delivery, summed up before the contract's cap and an approved waiver apply to the total
+type penaltyTotal struct {
+	penalty         Money
+	uncappedPenalty Money
+	exemptedPenalty Money
+	exemption       *PenaltyExemption
+	isLateDelivery  bool
+}
+
+/*
+ * Add the penalty of a part of the delivery, evaluated without waiver.
+ */
+func (total *penaltyTotal) add(evaluation delayEvaluation) {
+	total.penalty = total.penalty.add(evaluation.penalty)
+	total.uncappedPenalty = total.uncappedPenalty.add(evaluation.uncappedPenalty)
+	total.exemptedPenalty = total.exemptedPenalty.add(evaluation.exemptedPenalty)
+	if evaluation.exemption != nil {
+		total.exemption = evaluation.exemption
+	}
+	if evaluation.isLateDelivery {
+		total.isLateDelivery = true
+	}
+}
+
+/*
+ * Cap the total penalty on the amount of the whole delivery, waive it as far as approved and write it to the evaluation.
+ * A bonus for early delivery is not waived.
+ */
+func (total penaltyTotal) applyTo(evaluation *delayEvaluation, amount Money, terms PenaltyTerms, waiver *PenaltyWaiver) {
+	penalty := terms.capPenalty(amount, total.penalty)
+	remaining, waived := penalty, Money{}
+	if penalty.sign() > 0 {
+		remaining, waived = waiver.apply(penalty, terms.roundingMode())
+	}
+
+	evaluation.penalty = remaining
+	evaluation.uncappedPenalty = total.uncappedPenalty
+	evaluation.exemptedPenalty = total.exemptedPenalty
+	evaluation.exemption = total.exemption
+	evaluation.isLateDelivery = total.isLateDelivery
+	evaluation.delayPenalty = remaining.format(moneyDecimalPlaces)
+	evaluation.uncappedDelayPenalty = total.uncappedPenalty.format(moneyDecimalPlaces)
+	evaluation.waivedDelayPenalty = total.exemptedPenalty.format(moneyDecimalPlaces)
+	evaluation.waiverDelayPenalty = waived.format(moneyDecimalPlaces)
+}
+
+/*
+ * Evaluate status and penalty of a material delivered in several receipts on the as-of date. Every receipt is
+ * evaluated on its own with its share of the value, so that only the late portion incurs a penalty. The sum is
+ * capped as agreed in the contract before an approved waiver is applied. As long as not the whole ordered quantity
+ * is received the material is delayed or on time like an open delivery, with the penalty incurred so far.
+ */
+func evaluateDeliveries(invoiceAmount Money, terms PenaltyTerms, waiver *PenaltyWaiver, asOfDate time.Time, expectedDate string, deliveries MaterialDeliveries) delayEvaluation {
+	ordered, _ := parseMoney(deliveries.OrderedQuantity)
+	received := Money{}
+
+	var total penaltyTotal
+	var latestReceipt *delayEvaluation
+	var latestActualDate time.Time
+
+	for _, receipt := range deliveries.Receipts {
+		actualDate, _ := time.Parse(timeFormat, receipt.ActualDate)
+
+		// a receipt after the as-of date had not happened yet on that date
+		if actualDate.After(asOfDate) {
+			continue
+		}
+
+		// receipt without quantity delivers the rest
+		quantity := ordered.sub(received)
+		if receipt.ReceivedQuantity != "" {
+			quantity, _ = parseMoney(receipt.ReceivedQuantity)
+		}
+		received = received.add(quantity)
+
+		value := invoiceAmount.mul(new(big.Rat).Quo(quantity.rat(), ordered.rat()))
+		if unitPrice, err := parseMoney(deliveries.UnitPrice); err == nil {
+			value = unitPrice.mul(quantity.rat())
+		}
+
+		evaluation := evaluateDelay(value, terms, nil, asOfDate, expectedDate, receipt.ActualDate, receipt.DelayReason)
+		total.add(evaluation)
+
+		if latestReceipt == nil || !actualDate.Before(latestActualDate) {
+			latestReceipt = &evaluation
+			latestActualDate = actualDate
+		}
+	}
+
+	// material is delivered completely once the ordered quantity is received, status is the one of the last receipt
+	var evaluation delayEvaluation
+	isComplete := latestReceipt != nil && received.cmp(ordered) >= 0
+	if isComplete {
+		evaluation = *latestReceipt
+	} else {
+		evaluation = evaluateDelay(invoiceAmount, terms, nil, asOfDate, expectedDate, "", "")
+	}
+
+	// penalty incurred so far is known even while the rest is open, it is final once the whole quantity is received
+	if latestReceipt != nil {
+		total.isLateDelivery = total.isLateDelivery && isComplete
+		total.applyTo(&evaluation, invoiceAmount, terms, waiver)
+	}
+
+	evaluation.orderedQuantity = ordered.String()
+	evaluation.receivedQuantity = received.String()
+
+	return evaluation
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateDeliveries(t *testing.T) {
+	tests := []struct {
+		name             string
+		contract         *PenaltyContract
+		waiver           *PenaltyWaiver
+		asOfDate         string
+		deliveries       MaterialDeliveries
+		wantStatus       string
+		wantDelayPenalty string
+		wantReceived     string
+		wantLate         bool
+	}{
+		{
+			name:       "delivered at once",
+			asOfDate:   "01/31/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{{ReceiptNumber: "1", ActualDate: "01/12/2020"}}},
+			wantStatus: "Delivered+2", wantDelayPenalty: "50.00", wantReceived: "10", wantLate: true,
+		},
+		{
+			name:     "only the late part is charged",
+			asOfDate: "01/31/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{
+				{ReceiptNumber: "1", ActualDate: "01/08/2020", ReceivedQuantity: "5"},
+				{ReceiptNumber: "2", ActualDate: "01/20/2020", ReceivedQuantity: "5"},
+			}},
+			wantStatus: "Delivered+10", wantDelayPenalty: "100.00", wantReceived: "10", wantLate: true,
+		},
+		{
+			name:       "partially received",
+			asOfDate:   "01/20/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{{ReceiptNumber: "1", ActualDate: "01/15/2020", ReceivedQuantity: "4"}}},
+			wantStatus: "Delayed+10", wantDelayPenalty: "40.00", wantReceived: "4", wantLate: false,
+		},
+		{
+			name:       "received after the as-of date",
+			asOfDate:   "01/20/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{{ReceiptNumber: "1", ActualDate: "01/25/2020"}}},
+			wantStatus: "Delayed+10", wantDelayPenalty: "-", wantReceived: "0", wantLate: false,
+		},
+		{
+			name:     "valued with the unit price",
+			asOfDate: "01/31/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", UnitPrice: "120", Receipts: []MaterialReceipt{
+				{ReceiptNumber: "1", ActualDate: "01/12/2020", ReceivedQuantity: "5"},
+				{ReceiptNumber: "2", ActualDate: "01/10/2020", ReceivedQuantity: "5"},
+			}},
+			wantStatus: "Delivered+2", wantDelayPenalty: "30.00", wantReceived: "10", wantLate: true,
+		},
+		{
+			name:       "waived as approved",
+			waiver:     &PenaltyWaiver{Status: waiverStatusApproved, WaivedPercent: 50},
+			asOfDate:   "01/31/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{{ReceiptNumber: "1", ActualDate: "01/20/2020"}}},
+			wantStatus: "Delivered+10", wantDelayPenalty: "100.00", wantReceived: "10", wantLate: true,
+		},
+		{
+			name:     "total is capped",
+			contract: &PenaltyContract{MaxPenaltyAmount: mustParseMoney(t, "120")},
+			asOfDate: "01/31/2020",
+			deliveries: MaterialDeliveries{OrderedQuantity: "10", Receipts: []MaterialReceipt{
+				{ReceiptNumber: "1", ActualDate: "01/20/2020", ReceivedQuantity: "5"},
+				{ReceiptNumber: "2", ActualDate: "01/20/2020", ReceivedQuantity: "5"},
+			}},
+			wantStatus: "Delivered+10", wantDelayPenalty: "120.00", wantReceived: "10", wantLate: true,
+		},
+	}
+
+	for _, test := range tests {
+		terms := PenaltyTerms{Contract: test.contract, Schedule: defaultPenaltySchedule()}
+		asOfDate, _ := time.Parse(timeFormat, test.asOfDate)
+
+		evaluation := evaluateDeliveries(mustParseMoney(t, "1000"), terms, test.waiver, asOfDate, "01/10/2020", test.deliveries)
+		if evaluation.status != test.wantStatus {
+			t.Errorf("%s: status = %s, want %s", test.name, evaluation.status, test.wantStatus)
+		}
+		if evaluation.delayPenalty != test.wantDelayPenalty {
+			t.Errorf("%s: delay penalty = %s, want %s", test.name, evaluation.delayPenalty, test.wantDelayPenalty)
+		}
+		if evaluation.receivedQuantity != test.wantReceived {
+			t.Errorf("%s: received quantity = %s, want %s", test.name, evaluation.receivedQuantity, test.wantReceived)
+		}
+		if evaluation.isLateDelivery != test.wantLate {
+			t.Errorf("%s: late delivery = %v, want %v", test.name, evaluation.isLateDelivery, test.wantLate)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// ISO 4217 currency code
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRate is the rate of one unit of FromCurrency in ToCurrency posted for a day.
+type ExchangeRate struct {
+	FromCurrency         string `json:"fromCurrency"`
+	ToCurrency           string `json:"toCurrency"`
+	RateDate             string `json:"rateDate"`
+	Rate                 string `json:"rate"`
+	PostedBy             string `json:"postedBy"`
+	IsExchangeRateObject bool   `json:"isExchangeRateObject"`
+}
+
+// conversion applied to amounts which are reported in another currency than the invoice's
+type currencyConversion struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         *big.Rat
+	RateDate     string
+}
+
+/*
+ * Key of the rate of a currency pair on a day, the date is written as yyyymmdd so that keys sort chronologically.
+ */
+func exchangeRateKey(fromCurrency string, toCurrency string, date time.Time) string {
+	return "FX-" + fromCurrency + "-" + toCurrency + "-" + date.Format("20060102")
+}
+
+/*
+ * Find the latest rate of a currency pair posted on or before the given date, returns nil rate if there is none.
+ */
+func findExchangeRate(stub shim.ChaincodeStubInterface, fromCurrency string, toCurrency string, date time.Time) (*ExchangeRate, error) {
+	startKey := "FX-" + fromCurrency + "-" + toCurrency + "-"
+	endKey := exchangeRateKey(fromCurrency, toCurrency, date.AddDate(0, 0, 1))
+
+	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var rate *ExchangeRate
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		rate = &ExchangeRate{}
+		json.Unmarshal(queryResponse.Value, rate)
+	}
+
+	return rate, nil
+}
+
+/*
+ * Resolve conversion between two currencies with the latest rate on or before the given date. The inverse of the
+ * opposite pair's rate is used when no rate was posted for the pair itself.
+ */
+func resolveCurrencyConversion(stub shim.ChaincodeStubInterface, fromCurrency string, toCurrency string, date time.Time) (currencyConversion, error) {
+	conversion := currencyConversion{FromCurrency: fromCurrency, ToCurrency: toCurrency, Rate: big.NewRat(1, 1)}
+
+	if fromCurrency == toCurrency {
+		return conversion, nil
+	}
+
+	rate, err := findExchangeRate(stub, fromCurrency, toCurrency, date)
+	if err != nil {
+		return conversion, err
+	}
+	if rate != nil {
+		conversion.Rate, _ = new(big.Rat).SetString(rate.Rate)
+		conversion.RateDate = rate.RateDate
+		return conversion, nil
+	}
+
+	inverseRate, err := findExchangeRate(stub, toCurrency, fromCurrency, date)
+	if err != nil {
+		return conversion, err
+	}
+	if inverseRate != nil {
+		conversion.Rate, _ = new(big.Rat).SetString(inverseRate.Rate)
+		conversion.Rate.Inv(conversion.Rate)
+		conversion.RateDate = inverseRate.RateDate
+		return conversion, nil
+	}
+
+	return conversion, fmt.Errorf("no exchange rate from %s to %s on or before %s", fromCurrency, toCurrency, date.Format(timeFormat))
+}
+
+/*
+ * Convert amount into the target currency and round it with the given rounding mode.
+ */
+func (conversion currencyConversion) convert(amount Money, roundingMode string) Money {
+	return amount.mul(conversion.Rate).round(moneyDecimalPlaces, roundingMode)
+}
+
+/*
+ * Function to post the exchange rate of a currency pair for a day, only organizations named in the chaincode config may post.
+ * A rate posted again for the same day replaces the previous one.
+ * 1st - from currency, 2nd - to currency, 3rd - date, 4th - rate e.g. 1.1375
+ */
+func (cc *Invoice) postExchangeRate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 4 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := checkCallerMsp(stub, config.FxRateMspIds); err != nil {
+		return Error(http.StatusForbidden, "Not allowed to post exchange rates: "+err.Error())
+	}
+
+	if !currencyPattern.MatchString(args[0]) || !currencyPattern.MatchString(args[1]) || args[0] == args[1] {
+		return Error(http.StatusNotAcceptable, "Currencies must be two different ISO 4217 codes")
+	}
+
+	rateDate, err := time.Parse(timeFormat, args[2])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Date must be in format "+timeFormat)
+	}
+
+	rate, err := parseMoney(args[3])
+	if err != nil || rate.sign() <= 0 {
+		return Error(http.StatusNotAcceptable, "Rate must be a positive decimal number")
+	}
+
+	callerMspId, _ := getCallerMspId(stub)
+
+	exchangeRate := &ExchangeRate{
+		FromCurrency:         args[0],
+		ToCurrency:           args[1],
+		RateDate:             args[2],
+		Rate:                 rate.String(),
+		PostedBy:             callerMspId,
+		IsExchangeRateObject: true,
+	}
+
+	// convert to byte
+	exchangeRateInBytes, _ := json.Marshal(exchangeRate)
+
+	// write exchange rate to BC
+	if err := stub.PutState(exchangeRateKey(args[0], args[1], rateDate), exchangeRateInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusCreated, "Exchange Rate Posted Successsfully!", nil)
+}
+
+/*
+ * Function to get the exchange rate of a currency pair in effect on a day, which is the latest one posted on or before it.
+ * 1st - from currency, 2nd - to currency, 3rd - date
+ */
+func (cc *Invoice) getExchangeRate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	date, err := time.Parse(timeFormat, args[2])
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Date must be in format "+timeFormat)
+	}
+
+	rate, err := findExchangeRate(stub, args[0], args[1], date)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if rate == nil {
+		return Error(http.StatusNotFound, "No exchange rate from "+args[0]+" to "+args[1]+" on or before "+args[2])
+	}
+
+	rateInBytes, _ := json.Marshal(rate)
+	return Success(http.StatusOK, "OK", rateInBytes)
+}
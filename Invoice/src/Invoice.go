@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"time"
-	"strconv"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
@@ -35,11 +34,165 @@ func toChaincodeArgs(args ...string) [][]byte {
 	return bargs
 }
 
+// get optional parameter at index i, empty string if it was not passed
+func optionalArg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+/*
+ * Get date penalties are evaluated on. It is the explicit as-of date if passed, otherwise the date of the transaction
+ * timestamp, which is the same on every endorsing peer unlike the local clock.
+ */
+func getEvaluationDate(stub shim.ChaincodeStubInterface, asOfDate string) (time.Time, error) {
+	if asOfDate != "" {
+		return time.Parse(timeFormat, asOfDate)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// strip time of day, penalties are calculated on whole days
+	return time.Parse(timeFormat, txTime.Format(timeFormat))
+}
+
+/*
+ * Get time of the transaction timestamp in UTC.
+ */
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), nil
+}
+
+/*
+ * Read invoice by invoice number from blockchain, returns nil invoice when it does not exist.
+ */
+func getInvoiceById(stub shim.ChaincodeStubInterface, invoiceNumber string) (*Invoice, error) {
+	invoiceInBytes, err := stub.GetState("IN-" + invoiceNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if invoiceInBytes == nil {
+		return nil, nil
+	}
+
+	var invoice Invoice
+	if err := json.Unmarshal(invoiceInBytes, &invoice); err != nil {
+		return nil, err
+	}
+
+	// invoices created before invoice numbers were introduced are numbered after material and purchase order
+	if invoice.InvoiceNumber == "" {
+		invoice.InvoiceNumber = defaultInvoiceNumber(invoice.In_MaterialNumber, invoice.In_PurchaseOrderNumber)
+	}
+
+	return &invoice, nil
+}
+
+/*
+ * Read all invoices of a material of a purchase order from blockchain in the order of their invoice numbers.
+ */
+func getInvoicesForDelivery(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) ([]Invoice, error) {
+	invoices := []Invoice{}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(invoiceIndexName, []string{purchaseOrderNumber, materialNumber})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := stub.SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		invoice, err := getInvoiceById(stub, keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		if invoice != nil {
+			invoices = append(invoices, *invoice)
+		}
+	}
+
+	// invoices created before the index was introduced are only found by their key
+	if len(invoices) == 0 {
+		invoice, err := getInvoiceById(stub, defaultInvoiceNumber(materialNumber, purchaseOrderNumber))
+		if err != nil {
+			return nil, err
+		}
+		if invoice != nil {
+			invoices = append(invoices, *invoice)
+		}
+	}
+
+	return invoices, nil
+}
+
+/*
+ * Write invoice to blockchain and index it by purchase order and material.
+ */
+func putInvoice(stub shim.ChaincodeStubInterface, invoice Invoice) error {
+	// convert to byte
+	invoiceInBytes, _ := json.Marshal(invoice)
+
+	// write Invoice and details to BC
+	if err := stub.PutState("IN-"+invoice.InvoiceNumber, invoiceInBytes); err != nil {
+		return err
+	}
+
+	indexKey, err := stub.CreateCompositeKey(invoiceIndexName, []string{invoice.In_PurchaseOrderNumber, invoice.In_MaterialNumber, invoice.InvoiceNumber})
+	if err != nil {
+		return err
+	}
+
+	// index entry only needs the key, a value is required to write it
+	return stub.PutState(indexKey, []byte{0x00})
+}
+
+/*
+ * Get invoice number of an invoice created without one, it has the key invoices had before invoice numbers were introduced.
+ */
+func defaultInvoiceNumber(materialNumber string, purchaseOrderNumber string) string {
+	return materialNumber + "-" + purchaseOrderNumber
+}
+
+// name of the composite key index of invoices by purchase order and material
+const invoiceIndexName = "po~material~invoice"
+
+// format of all dates handled by the chaincode
+const timeFormat = "01/02/2006"
 
 type Invoice struct {
+	InvoiceNumber string `json:"invoiceNumber"`
 	In_MaterialNumber string `json:"in_MaterialNumber"`
 	In_PurchaseOrderNumber string `json:"in_PurchaseOrderNumber"`
 	InvoiceAmount string `json:"invoiceAmount"`
+	Currency string `json:"currency"`
+	SupplierCode string `json:"supplierCode"`
+	NetAmount string `json:"netAmount"`
+	TaxAmount string `json:"taxAmount"`
+	Lines []InvoiceLine `json:"lines"`
+	Taxes []InvoiceTax `json:"taxes"`
+	InvoiceStatus string `json:"invoiceStatus"`
+	PaidAmount string `json:"paidAmount"`
+	Version int `json:"version"`
+	History []WorkflowEvent `json:"history"`
 }
 
 func main() {
@@ -50,6 +203,34 @@ func main() {
 
 // Init is called during Instantiate transaction.
 func (cc *Invoice) Init(stub shim.ChaincodeStubInterface) peer.Response {
+
+	// optional 1st parameter - chaincode config as json, the stored config is kept when it is not passed
+	if _, args := stub.GetFunctionAndParameters(); len(args) > 0 && args[0] != "" {
+		if response := putChaincodeConfig(stub, args[0]); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	// write default penalty schedule if it is not present yet, Init is called on upgrade as well
+	if schedule, err := getPenaltyScheduleById(stub, defaultPenaltyScheduleId); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	} else if schedule == nil {
+		if response := putPenaltySchedule(stub, defaultPenaltySchedule()); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	// write default delay reasons which are not present yet
+	for _, reason := range defaultDelayReasons() {
+		if existing, err := getDelayReasonByCode(stub, reason.ReasonCode); err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		} else if existing == nil {
+			if response := putDelayReason(stub, reason); response.Status != http.StatusOK {
+				return response
+			}
+		}
+	}
+
 	return Success(http.StatusOK, "OK", nil)
 }
 
@@ -63,38 +244,172 @@ func (cc *Invoice) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 			return cc.createInvoice(stub, args)	
 		case "getInvoiceAmountById":
 			return cc.getInvoiceAmountById(stub, args)
+		case "submitInvoice":
+			return cc.submitInvoice(stub, args)
+		case "approveInvoice":
+			return cc.approveInvoice(stub, args)
+		case "disputeInvoice":
+			return cc.disputeInvoice(stub, args)
+		case "cancelInvoice":
+			return cc.cancelInvoice(stub, args)
+		case "recordInvoicePayment":
+			return cc.recordInvoicePayment(stub, args)
+		case "amendInvoice":
+			return cc.amendInvoice(stub, args)
+		case "getInvoiceHistory":
+			return cc.getInvoiceHistory(stub, args)
+		case "matchInvoice":
+			return cc.matchInvoice(stub, args)
+		case "createPenaltySchedule":
+			return cc.createPenaltySchedule(stub, args)
+		case "updatePenaltySchedule":
+			return cc.updatePenaltySchedule(stub, args)
+		case "getPenaltySchedule":
+			return cc.getPenaltySchedule(stub, args)
+		case "createPenaltyContract":
+			return cc.createPenaltyContract(stub, args)
+		case "renewPenaltyContract":
+			return cc.renewPenaltyContract(stub, args)
+		case "getPenaltyContract":
+			return cc.getPenaltyContract(stub, args)
+		case "getPenaltyContractsBySupplier":
+			return cc.getPenaltyContractsBySupplier(stub, args)
+		case "createCalendar":
+			return cc.createCalendar(stub, args)
+		case "updateCalendar":
+			return cc.updateCalendar(stub, args)
+		case "getCalendar":
+			return cc.getCalendar(stub, args)
+		case "postExchangeRate":
+			return cc.postExchangeRate(stub, args)
+		case "getExchangeRate":
+			return cc.getExchangeRate(stub, args)
+		case "createDelayReason":
+			return cc.createDelayReason(stub, args)
+		case "getAllDelayReasons":
+			return cc.getAllDelayReasons(stub, args)
+		case "registerSupplier":
+			return cc.registerSupplier(stub, args)
+		case "getSupplier":
+			return cc.getSupplier(stub, args)
+		case "requestPenaltyWaiver":
+			return cc.requestPenaltyWaiver(stub, args)
+		case "approvePenaltyWaiver":
+			return cc.approvePenaltyWaiver(stub, args)
+		case "rejectPenaltyWaiver":
+			return cc.rejectPenaltyWaiver(stub, args)
+		case "getPenaltyWaiver":
+			return cc.getPenaltyWaiver(stub, args)
+		case "openPenaltyDispute":
+			return cc.openPenaltyDispute(stub, args)
+		case "submitDisputeEvidence":
+			return cc.submitDisputeEvidence(stub, args)
+		case "reviewPenaltyDispute":
+			return cc.reviewPenaltyDispute(stub, args)
+		case "resolvePenaltyDispute":
+			return cc.resolvePenaltyDispute(stub, args)
+		case "getPenaltyDispute":
+			return cc.getPenaltyDispute(stub, args)
+		case "issueCreditNote":
+			return cc.issueCreditNote(stub, args)
+		case "settleCreditNote":
+			return cc.settleCreditNote(stub, args)
+		case "getCreditNotes":
+			return cc.getCreditNotes(stub, args)
 		default:
-			return Error(http.StatusNotImplemented, "Invalid method! Valid methods are 'createInvoice|getInvoiceAmountById'!")
+			return Error(http.StatusNotImplemented, "Invalid method! Valid methods are 'createInvoice|getInvoiceAmountById|submitInvoice|approveInvoice|disputeInvoice|cancelInvoice|recordInvoicePayment|amendInvoice|getInvoiceHistory|matchInvoice|createPenaltySchedule|updatePenaltySchedule|getPenaltySchedule|createPenaltyContract|renewPenaltyContract|getPenaltyContract|getPenaltyContractsBySupplier|createCalendar|updateCalendar|getCalendar|postExchangeRate|getExchangeRate|createDelayReason|getAllDelayReasons|registerSupplier|getSupplier|requestPenaltyWaiver|approvePenaltyWaiver|rejectPenaltyWaiver|getPenaltyWaiver|openPenaltyDispute|submitDisputeEvidence|reviewPenaltyDispute|resolvePenaltyDispute|getPenaltyDispute|issueCreditNote|settleCreditNote|getCreditNotes'!")
 	}
 }
 
 /*
  * Function to create invoice and store invoice amount onto blockchain for a specific purchase order and material number.
+ * Purchase order and expected material information have to exist in the demand chaincode.
+ * 1st - material number, 2nd - purchase order #, 3rd - invoice amount
+ * optional: 4th - currency, defaults to base currency of the config, 5th - supplier code, needed for the supplier to take part in waivers,
+ * defaults to the supplier of the purchase order and has to match it,
+ * 6th - invoice number, defaults to material number and purchase order #, several invoices of a material need their own numbers
+ * or: 1st - invoice with lines and taxes as json as posted by the ERP, its penalty is calculated on the net amount of the lines
  */
 func (cc *Invoice) createInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 
 	// check total parameters
-	if len(args) != 3 {
+	if len(args) != 1 && (len(args) < 3 || len(args) > 6) {
 		return Error(http.StatusNotAcceptable, "Invalid parameters!")
 	}
-	
+
+	// create json for invoice object, it is a draft until it is submitted for approval
+	var info *Invoice
+	if len(args) == 1 {
+		invoice, err := parseInvoiceDocument(args[0])
+		if err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid invoice: "+err.Error())
+		}
+		info = &invoice
+	} else {
+		// invoice amount has to be a decimal number, otherwise no penalty could be calculated on it
+		if _, err := parseMoney(args[2]); err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid invoice amount: "+err.Error())
+		}
+
+		info = &Invoice{
+			In_MaterialNumber:      args[0],
+			In_PurchaseOrderNumber: args[1],
+			InvoiceAmount:          args[2],
+			Currency:               optionalArg(args, 3),
+			SupplierCode:           optionalArg(args, 4),
+			InvoiceNumber:          optionalArg(args, 5),
+		}
+	}
+	if info.InvoiceNumber == "" {
+		info.InvoiceNumber = defaultInvoiceNumber(info.In_MaterialNumber, info.In_PurchaseOrderNumber)
+	}
+	info.InvoiceStatus = invoiceStatusDraft
+	info.PaidAmount = ""
+	info.Version = 1
+	info.History = []WorkflowEvent{}
+
+	currency := info.Currency
+	if currency == "" {
+		config, err := getChaincodeConfig(stub)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+		currency = config.BaseCurrency
+	}
+	if currency != "" && !currencyPattern.MatchString(currency) {
+		return Error(http.StatusNotAcceptable, "Invalid currency: must be an ISO 4217 code")
+	}
+	info.Currency = currency
+
 	// Check if Invoice already exists
-	if validateValue, validateErr := stub.GetState("IN-"+args[0]+"-"+args[1]); validateErr != nil || validateValue != nil {
-		return Error(http.StatusConflict, "Invoice already exists")
+	if validateValue, validateErr := stub.GetState("IN-" + info.InvoiceNumber); validateErr != nil || validateValue != nil {
+		return Error(http.StatusConflict, "Invoice "+info.InvoiceNumber+" already exists")
 	}
 
-	// create json for invoice object
-	info := &Invoice{
-		In_MaterialNumber: args[0],
-		In_PurchaseOrderNumber: args[1],
-		InvoiceAmount: args[2],
+	// invoice has to reference a purchase order and a material expected on it
+	material, response := getPurchaseOrderMaterial(stub, info.In_MaterialNumber, info.In_PurchaseOrderNumber)
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if info.SupplierCode == "" {
+		info.SupplierCode = material.SupplierCode
+	} else if info.SupplierCode != material.SupplierCode {
+		return Error(http.StatusNotAcceptable, "Supplier code "+info.SupplierCode+" does not match supplier "+material.SupplierCode+" of purchase order "+info.In_PurchaseOrderNumber)
 	}
 
-	// convert to byte
-	jsonInvoiceInfo, _ := json.Marshal(info)
+	// penalty of a material is allocated across its invoices, so they have to be in the same currency
+	deliveryInvoices, err := getInvoicesForDelivery(stub, info.In_MaterialNumber, info.In_PurchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	for _, deliveryInvoice := range deliveryInvoices {
+		if deliveryInvoice.Currency != "" && deliveryInvoice.Currency != info.Currency {
+			return Error(http.StatusNotAcceptable, "Invoices of a material of a purchase order must be in the same currency, invoice "+deliveryInvoice.InvoiceNumber+" is in "+deliveryInvoice.Currency)
+		}
+	}
 
 	// write Invoice and details to BC
-	if err := stub.PutState("IN-"+args[0]+"-"+args[1], jsonInvoiceInfo); err != nil {
+	if err := putInvoice(stub, *info); err != nil {
 		return Error(http.StatusInternalServerError, err.Error())
 	}
 
@@ -106,52 +421,106 @@ func (cc *Invoice) createInvoice(stub shim.ChaincodeStubInterface, args []string
  * Function to get Invoice amount by purchase order id and material number
  */
 func (cc *Invoice) getInvoiceAmountById(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	// 1st - purchase order #,  2nd - MaterialNumber, 3rd - expected date, 4th - actual date
+	// 1st - purchase order #,  2nd - MaterialNumber, 3rd - material as json with supplier code, expected date,
+	// goods receipts with their delay reasons and reschedules, as returned by getPurchaseOrderMaterial of the demand chaincode
+	// optional: 4th - as-of date to evaluate on, defaults to transaction date,
+	// 5th - currency to report amounts in, defaults to the invoice's currency
 	
 	// check total parameters
-	if len(args) != 4 {
+	if len(args) < 3 || len(args) > 5 {
 		return Error(http.StatusNotAcceptable, "Invalid parameters!")
 	}
-	
-	// query for bc to fetch all the blocks where purchase order number = arsg[0] and material number  = args[1]
-	queryString := fmt.Sprintf("{\"selector\":{\"in_PurchaseOrderNumber\":\""+args[0]+"\",\"in_MaterialNumber\":\""+args[1]+"\"}}")
-	
-	// invoke query
-	resultsIterator, err := stub.GetQueryResult(queryString)
 
-	// if error, return error as response
+	// the demand chaincode calls this function, so the material is passed instead of being read from it
+	material, err := parsePurchaseOrderMaterial(args[2], args[1], args[0])
 	if err != nil {
-		return Error(http.StatusInternalServerError, err.Error())
+		return Error(http.StatusNotAcceptable, "Invalid material: "+err.Error())
+	}
+	deliveries, err := material.deliveries()
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid deliveries: "+err.Error())
 	}
 
-	// if no error, close the iterator and return
-	defer resultsIterator.Close()
+	asOfDate, err := getEvaluationDate(stub, optionalArg(args, 3))
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid as-of date: "+err.Error())
+	}
+
+	// fetch all invoices of the material of the purchase order, the material's penalty is allocated across them
+	invoices, err := getInvoicesForDelivery(stub, args[1], args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
 
 	// buffer is a JSON array containing QueryResults
 	var buffer bytes.Buffer
 	
-	// iterate over all the blocks of data obtained as a result from the query
-	for resultsIterator.HasNext() {
+	if len(invoices) > 0 {
+		// material, purchase order and currency are the same for all invoices of a material
+		invoiceData := invoices[0]
+
+		// invoices created before suppliers were kept on them are of the supplier of the purchase order
+		supplierCode := invoiceData.SupplierCode
+		if supplierCode == "" {
+			supplierCode = material.SupplierCode
+		}
 
-		// fetch idividual block
-		queryResponse, err1 := resultsIterator.Next()
+		// contract of the supplier in force on the baseline date the reschedules leave with penalty schedule and calendar
+		// used to calculate delay penalty, delays are measured from the baseline date
+		terms, baselineDate, err := resolveBaselinePenaltyTerms(stub, supplierCode, *material)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
 
-		// if error fetching individual block, return error in response
-		if err1 != nil {
-			return shim.Error(err1.Error())
+		// invoices created before currencies were introduced are in base currency
+		if invoiceData.Currency == "" {
+			config, err := getChaincodeConfig(stub)
+			if err != nil {
+				return Error(http.StatusInternalServerError, err.Error())
+			}
+			invoiceData.Currency = config.BaseCurrency
 		}
-		
-		// define a var for Invoice json structure
-		var invoiceData Invoice
 
-		// convert bytes to json
-		json.Unmarshal(queryResponse.Value,&invoiceData)
+		reportingCurrency := optionalArg(args, 4)
+		if reportingCurrency == "" {
+			reportingCurrency = invoiceData.Currency
+		}
 
-		// create invoice object
-		buffer = createInvoiceObject(invoiceData,args[2],args[3],buffer)
+		// conversion of invoice amount and penalty into reporting currency with rate in effect on as-of date
+		conversion, err := resolveCurrencyConversion(stub, invoiceData.Currency, reportingCurrency, asOfDate)
+		if err != nil {
+			return Error(http.StatusNotFound, err.Error())
+		}
+
+		// waiver of the penalty, it is only applied once approved by buyer and supplier
+		waiver, err := getPenaltyWaiverById(stub, invoiceData.In_MaterialNumber, invoiceData.In_PurchaseOrderNumber)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+
+		// dispute of the penalty, which may freeze it or correct the actual date
+		dispute, err := getPenaltyDisputeById(stub, invoiceData.In_MaterialNumber, invoiceData.In_PurchaseOrderNumber)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+		evaluationDate, actualDate := dispute.evaluationDates(asOfDate, material.ActualDate)
+
+		// credit note the penalty was deducted with once it was final
+		creditNote, err := getCreditNoteById(stub, invoiceData.In_MaterialNumber, invoiceData.In_PurchaseOrderNumber)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+
+		// evaluate delivery at once or receipt by receipt
+		if deliveries != nil {
+			correctedDeliveries := dispute.correctDeliveries(*deliveries)
+			deliveries = &correctedDeliveries
+		}
+		evaluation := evaluateInvoices(invoices,terms,waiver,evaluationDate,baselineDate,actualDate,material.DelayReason,deliveries)
+		evaluation.baselineDate = baselineDate
 
-		// because only latest block for purchase order and material number needs to be sent as the response
-		break;
+		// create invoice object
+		buffer = createInvoiceObject(invoiceData,invoices,terms,conversion,waiver,dispute,creditNote,evaluation,evaluationDate,material.DelayReason,buffer)
 	}
 	
 	// return bytes with success status
@@ -161,98 +530,70 @@ func (cc *Invoice) getInvoiceAmountById(stub shim.ChaincodeStubInterface, args [
 /**
  * Function to create invoice object which will be sent as the response in form of bytes
  */
-func createInvoiceObject(invoiceData Invoice,expectedDate string,actualDate string,buffer bytes.Buffer) (xy bytes.Buffer) {
+func createInvoiceObject(invoiceData Invoice,invoices []Invoice,terms PenaltyTerms,conversion currencyConversion,waiver *PenaltyWaiver,dispute *PenaltyDispute,creditNote *CreditNote,evaluation delayEvaluation,asOfDate time.Time,delayReason string,buffer bytes.Buffer) (xy bytes.Buffer) {
+	// amounts of the material are the sums over its invoices which are not cancelled
+	invoiceAmount := Money{}
+	netAmount := Money{}
+	taxAmount := Money{}
+	paidAmount := Money{}
+	for _, invoice := range activeInvoices(invoices) {
+		grossAmount, _ := parseMoney(invoice.InvoiceAmount)
+		invoiceTaxAmount, _ := parseMoney(invoice.TaxAmount)
+		invoicePaidAmount, _ := parseMoney(invoice.PaidAmount)
+
+		invoiceAmount = invoiceAmount.add(grossAmount)
+		netAmount = netAmount.add(invoice.netAmount())
+		taxAmount = taxAmount.add(invoiceTaxAmount)
+		paidAmount = paidAmount.add(invoicePaidAmount)
+	}
+
 	// store invoice amount in buffer
 	buffer.WriteString("{\"invoiceAmount\":")
 	buffer.WriteString("\"")
-	buffer.WriteString(invoiceData.InvoiceAmount)
+	buffer.WriteString(invoiceAmount.String())
 	buffer.WriteString("\"")
 
-	// time format
-	timeFormat := "01/02/2006"
-
-	// get current date - it should come from request parameter in actual prod version, because different nodes could be 
-	//geographically located in different region and this transaction could be rejected by validating nodes
-	currDate := time.Now()
-	
-	// convert curr date into string
-	currDateInStr := currDate.Format(timeFormat)
-
-	// variables to keep track of status, state and penalty amount
-	status := "" 
-	state := ""
-	delayPenalty := ""
-
-	// case 1: when expected date is not empty but actual date is empty
-	if expectedDate != "" && actualDate == "" {
-		// get difference between current date and expected date
-		currDateInDateFormat, _ := time.Parse(timeFormat,currDateInStr)
-		expectedDateInDateFormat, _ := time.Parse(timeFormat,expectedDate)
-
-		diff := currDateInDateFormat.Sub(expectedDateInDateFormat)
-
-		// get diff in terms of days
-		dayDiff := (diff.Hours())/24
-
-		// case 1.1: when day diff is great then 0, then material delivery is delayed
-		if dayDiff > float64(0) {
-			// diff between curr date and expected is > 0 and actual date is not present, delayed + diff between currDate and ExpectedDate
-			x := fmt.Sprintf("%.0f",dayDiff)
-			status = "Delayed+"+x
-			state = "Error"
-			delayPenalty = "-"
-		} else {	// case 1.2: if expected date is greater then current date, it is assumed material will be delivered on time.
-			// on time
-			status = "On-Time"
-			state = "Success"
-			delayPenalty = "0.00"
-		}
+	// store currency in buffer
+	buffer.WriteString(",\"currency\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(invoiceData.Currency)
+	buffer.WriteString("\"")
 
-	} else if expectedDate != "" && actualDate != "" { // case 2: when expected and actual date are present
-		// parsing expected date to date format from string
-		ed, _ := time.Parse(timeFormat,expectedDate)
+	// store net and tax amount in buffer, penalties are calculated on the net amount
+	buffer.WriteString(",\"netAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(netAmount.String())
+	buffer.WriteString("\"")
 
-		// parsing actual date to date format from string
-		ad, _ := time.Parse(timeFormat,actualDate)
-		
-		// calculate diff between expected and actual date
-		duration := ad.Sub(ed)
+	buffer.WriteString(",\"taxAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(taxAmount.String())
+	buffer.WriteString("\"")
 
-		// diff in days
-		days := (duration.Hours())/24
+	// store state of the invoices in their lifecycle and the amount paid so far in buffer
+	buffer.WriteString(",\"invoiceStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(deliveryInvoiceStatus(invoices))
+	buffer.WriteString("\"")
 
-		// case 2.1: when diff in days is less then or equal to 0, it is considered that material is delivered to manufacturer with 0 penalty
-		if days <= float64(0) {
-			// delivered
-			status = "Delivered"
-			state = "None"
-			delayPenalty = "0.00"
-		} else { // case 2.2: when diff in days is greater than zero, it is considered that material is delivered but with diff in days delay and penalty will be incurred
-			y := fmt.Sprintf("%.0f",days)
-			status = "Delivered+"+y
-			state = "Error"
-
-			// current penalty percentage
-			penaltyPercentage := 0
-					
-			// assign penalty pecentage based on diff in # of days
-			if 	days > float64(0) && days <= float64(2) {
-				penaltyPercentage = 5
-			}else if days > float64(2) && days <= float64(7) {
-				penaltyPercentage = 10
-			}else if days > float64(7) {
-				penaltyPercentage = 20
-			}
-			
-			// calculate penalty amount
-			InvoiceAmountFloat, _ := strconv.ParseFloat((invoiceData.InvoiceAmount),32)
-			InvoicePenalty := (InvoiceAmountFloat*float64(penaltyPercentage)) / 100
-			invoicePenaltyStr := fmt.Sprintf("%.2f", InvoicePenalty );
+	buffer.WriteString(",\"paidAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(paidAmount.String())
+	buffer.WriteString("\"")
 
-			delayPenalty = invoicePenaltyStr
-		}
+	// store invoices with their lines, tax breakdown and the part of the penalty allocated to them in buffer
+	invoicesInBytes, _ := json.Marshal(allocateToInvoices(invoices,evaluation,terms.roundingMode()))
+	buffer.WriteString(",\"invoices\":")
+	buffer.Write(invoicesInBytes)
 
-	} 
+	// status, state and penalty amount of the delivery
+	status := evaluation.status
+	state := evaluation.state
+	delayPenalty := evaluation.delayPenalty
+	uncappedDelayPenalty := evaluation.uncappedDelayPenalty
+	waivedDelayPenalty := evaluation.waivedDelayPenalty
+	exemption := evaluation.exemption
+	waiverDelayPenalty := evaluation.waiverDelayPenalty
 
 	// store status in buffer
 	buffer.WriteString(",\"status\":")
@@ -270,8 +611,317 @@ func createInvoiceObject(invoiceData Invoice,expectedDate string,actualDate stri
 	buffer.WriteString(",\"delayPenalty\":")
 	buffer.WriteString("\"")
 	buffer.WriteString(delayPenalty)
+	buffer.WriteString("\"")
+
+	// store uncapped delayPenalty in buffer
+	buffer.WriteString(",\"uncappedDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(uncappedDelayPenalty)
+	buffer.WriteString("\"")
+
+	// store quantities in buffer, empty when the material is delivered at once
+	buffer.WriteString(",\"orderedQuantity\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(evaluation.orderedQuantity)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"receivedQuantity\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(evaluation.receivedQuantity)
+	buffer.WriteString("\"")
+
+	// store delay reason and the exemption applied for it in buffer, exemption is empty when no penalty was waived
+	exemptionReasonCode := ""
+	waivedPercent := float64(0)
+	if exemption != nil {
+		exemptionReasonCode = exemption.ReasonCode
+		waivedPercent = exemption.WaivedPercent
+	}
+
+	buffer.WriteString(",\"delayReason\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(delayReason)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"exemption\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(exemptionReasonCode)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"waivedPercent\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(fmt.Sprintf("%v",waivedPercent))
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"waivedDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(waivedDelayPenalty)
+	buffer.WriteString("\"")
+
+	// store waiver with its history and the amount it waived in buffer
+	waiverStatus := ""
+	waiverInBytes := []byte("null")
+	if waiver != nil {
+		waiverStatus = waiver.Status
+		waiverInBytes, _ = json.Marshal(waiver)
+	}
+
+	buffer.WriteString(",\"waiverStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(waiverStatus)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"waiverDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(waiverDelayPenalty)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"waiver\":")
+	buffer.Write(waiverInBytes)
+
+	// store dispute status in buffer, the penalty is frozen on the day the dispute was opened while it is open
+	disputeStatus := ""
+	penaltyFrozenOn := ""
+	if dispute != nil {
+		disputeStatus = dispute.Status
+		if dispute.isOpen() {
+			penaltyFrozenOn = dispute.OpenedOn
+		}
+	}
+
+	buffer.WriteString(",\"disputeStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(disputeStatus)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"penaltyFrozenOn\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(penaltyFrozenOn)
+	buffer.WriteString("\"")
+
+	// store credit note of the penalty in buffer, empty as long as none was issued
+	creditNoteNumber := ""
+	creditNoteStatus := ""
+	if creditNote != nil {
+		creditNoteNumber = creditNote.CreditNoteNumber
+		creditNoteStatus = creditNote.Status
+	}
+
+	buffer.WriteString(",\"creditNoteNumber\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(creditNoteNumber)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"creditNoteStatus\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(creditNoteStatus)
+	buffer.WriteString("\"")
+
+	// store amount and penalty converted into reporting currency in buffer, a pending penalty stays pending
+	reportingDelayPenalty := delayPenalty
+	if delayPenaltyAmount, err := parseMoney(delayPenalty); err == nil {
+		reportingDelayPenalty = conversion.convert(delayPenaltyAmount,terms.roundingMode()).format(moneyDecimalPlaces)
+	}
+
+	buffer.WriteString(",\"reportingCurrency\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(conversion.ToCurrency)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"reportingInvoiceAmount\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(conversion.convert(invoiceAmount,terms.roundingMode()).format(moneyDecimalPlaces))
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"reportingDelayPenalty\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(reportingDelayPenalty)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"exchangeRate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(conversion.Rate.FloatString(6))
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"exchangeRateDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(conversion.RateDate)
+	buffer.WriteString("\"")
+
+	// store id of contract the penalty is based on, empty when default schedule is used
+	contractId := ""
+	if terms.Contract != nil {
+		contractId = terms.Contract.ContractId
+	}
+	buffer.WriteString(",\"contractId\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(contractId)
+	buffer.WriteString("\"")
+
+	buffer.WriteString(",\"penaltyBaselineDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(evaluation.baselineDate)
+	buffer.WriteString("\"")
+
+	// store date the status and penalty were evaluated on
+	buffer.WriteString(",\"asOfDate\":")
+	buffer.WriteString("\"")
+	buffer.WriteString(asOfDate.Format(timeFormat))
 	buffer.WriteString("\"}")
 
 	xy = buffer
 	return
-}
\ No newline at end of file
+}
+
+// status and delay penalty of a delivery as evaluated on a day
+type delayEvaluation struct {
+	status string
+	state string
+	delayPenalty string
+
+	// penalty before the contract's cap is applied
+	uncappedDelayPenalty string
+
+	// part of the penalty waived for an excusable delay reason
+	waivedDelayPenalty string
+	exemption *PenaltyExemption
+
+	// part of the penalty waived by an approved waiver
+	waiverDelayPenalty string
+
+	// penalty of a delivery which was recorded late, negative for the bonus of an early delivery
+	penalty Money
+	uncappedPenalty Money
+	exemptedPenalty Money
+	isLateDelivery bool
+
+	// quantities of a material delivered in several receipts, empty when delivered at once
+	orderedQuantity string
+	receivedQuantity string
+
+	// date delays are measured from, the expected date unless it was rescheduled
+	baselineDate string
+}
+
+/**
+ * Function to evaluate status and delay penalty of the delivery of a material on the as-of date, on the net amount of the lines
+ * of all its invoices which are not cancelled and receipt by receipt when the material is delivered in several parts
+ */
+func evaluateInvoices(invoices []Invoice,terms PenaltyTerms,waiver *PenaltyWaiver,asOfDate time.Time,expectedDate string,actualDate string,delayReason string,deliveries *MaterialDeliveries) delayEvaluation {
+	netAmount := Money{}
+	lines := []InvoiceLine{}
+	for _, invoice := range activeInvoices(invoices) {
+		netAmount = netAmount.add(invoice.netAmount())
+		lines = append(lines, invoice.penaltyLines()...)
+	}
+
+	if deliveries != nil {
+		return evaluateDeliveries(netAmount,terms,waiver,asOfDate,expectedDate,*deliveries)
+	}
+
+	// no invoice left to charge a penalty on
+	if len(lines) == 0 {
+		return evaluateDelay(netAmount,terms,waiver,asOfDate,expectedDate,actualDate,delayReason)
+	}
+
+	return evaluateInvoiceLines(lines,terms,waiver,asOfDate,expectedDate,actualDate,delayReason)
+}
+
+/**
+ * Function to evaluate status and delay penalty of a delivery on the as-of date
+ */
+func evaluateDelay(invoiceAmount Money,terms PenaltyTerms,waiver *PenaltyWaiver,asOfDate time.Time,expectedDate string,actualDate string,delayReason string) (evaluation delayEvaluation) {
+	// a delivery after the as-of date had not happened yet on that date, so it is evaluated as pending
+	if actualDateInDateFormat, err := time.Parse(timeFormat,actualDate); err == nil && actualDateInDateFormat.After(asOfDate) {
+		actualDate = ""
+	}
+
+	evaluation = delayEvaluation{waivedDelayPenalty: "0.00", waiverDelayPenalty: "0.00"}
+
+	// case 1: when expected date is not empty but actual date is empty
+	if expectedDate != "" && actualDate == "" {
+		// get difference between as-of date and expected date
+		expectedDateInDateFormat, _ := time.Parse(timeFormat,expectedDate)
+
+		// get diff in terms of days, business days only if the contract says so
+		dayDiff := terms.delayDays(expectedDateInDateFormat,asOfDate)
+
+		// case 1.1: when day diff is great then 0, then material delivery is delayed
+		if dayDiff > float64(0) {
+			// diff between as-of date and expected is > 0 and actual date is not present, delayed + diff between as-of date and ExpectedDate
+			x := fmt.Sprintf("%.0f",dayDiff)
+			evaluation.status = "Delayed+"+x
+			evaluation.state = "Error"
+			evaluation.delayPenalty = "-"
+			evaluation.uncappedDelayPenalty = "-"
+		} else {	// case 1.2: if expected date is greater then as-of date, it is assumed material will be delivered on time.
+			// on time
+			evaluation.status = "On-Time"
+			evaluation.state = "Success"
+			evaluation.delayPenalty = "0.00"
+			evaluation.uncappedDelayPenalty = "0.00"
+		}
+
+	} else if expectedDate != "" && actualDate != "" { // case 2: when expected and actual date are present
+		// parsing expected date to date format from string
+		ed, _ := time.Parse(timeFormat,expectedDate)
+
+		// parsing actual date to date format from string
+		ad, _ := time.Parse(timeFormat,actualDate)
+		
+		// diff in days between expected and actual date, business days only if the contract says so
+		days := terms.delayDays(ed,ad)
+
+		// case 2.1: when diff in days is less then 0 and the contract rewards early delivery, the bonus is reported as negative penalty
+		if days < float64(0) && terms.Incentive != nil {
+			z := fmt.Sprintf("%.0f",-days)
+			evaluation.status = "Early-"+z
+			evaluation.state = "Success"
+
+			// calculate bonus amount from the incentive schedule tier the # of days early falls into
+			InvoiceIncentive := terms.calculateIncentive(invoiceAmount,-days)
+
+			evaluation.penalty = InvoiceIncentive.neg()
+			evaluation.uncappedPenalty = evaluation.penalty
+			evaluation.delayPenalty = evaluation.penalty.format(moneyDecimalPlaces)
+			evaluation.uncappedDelayPenalty = evaluation.delayPenalty
+		} else if days <= float64(0) { // case 2.2: when diff in days is less then or equal to 0, it is considered that material is delivered to manufacturer with 0 penalty
+			// delivered
+			evaluation.status = "Delivered"
+			evaluation.state = "None"
+			evaluation.delayPenalty = "0.00"
+			evaluation.uncappedDelayPenalty = "0.00"
+		} else { // case 2.3: when diff in days is greater than zero, it is considered that material is delivered but with diff in days delay and penalty will be incurred
+			y := fmt.Sprintf("%.0f",days)
+			evaluation.status = "Delivered+"+y
+			evaluation.state = "Error"
+
+			// calculate penalty amount from the schedule tier the diff in # of days after grace period falls into, capped as per contract
+			InvoicePenaltyUncapped, InvoicePenalty := terms.calculatePenalty(invoiceAmount,days)
+
+			// waive penalty as far as the contract exempts the delay reason
+			InvoicePenalty, InvoicePenaltyWaived, appliedExemption := terms.applyExemption(InvoicePenalty,delayReason)
+			evaluation.exemption = appliedExemption
+
+			// waive remaining penalty as far as buyer and supplier approved
+			InvoicePenalty, InvoicePenaltyWaivedByWaiver := waiver.apply(InvoicePenalty,terms.roundingMode())
+			evaluation.waiverDelayPenalty = InvoicePenaltyWaivedByWaiver.format(moneyDecimalPlaces)
+
+			evaluation.penalty = InvoicePenalty
+			evaluation.uncappedPenalty = InvoicePenaltyUncapped
+			evaluation.exemptedPenalty = InvoicePenaltyWaived
+			evaluation.isLateDelivery = true
+			evaluation.delayPenalty = InvoicePenalty.format(moneyDecimalPlaces)
+			evaluation.waivedDelayPenalty = InvoicePenaltyWaived.format(moneyDecimalPlaces)
+			evaluation.uncappedDelayPenalty = InvoicePenaltyUncapped.format(moneyDecimalPlaces)
+		}
+
+	} else if expectedDate == "" { // case 3: when the supplier has not confirmed the expected date yet, no penalty can be enforced
+		evaluation.status = "Unconfirmed"
+		evaluation.state = "Warning"
+		evaluation.delayPenalty = "0.00"
+		evaluation.uncappedDelayPenalty = "0.00"
+	}
+
+	return
+}
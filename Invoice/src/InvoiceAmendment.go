@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// InvoiceVersion is one version of an invoice as written by a transaction.
+type InvoiceVersion struct {
+	TxId      string   `json:"txId"`
+	Timestamp string   `json:"timestamp"`
+	IsDelete  bool     `json:"isDelete"`
+	Invoice   *Invoice `json:"invoice"`
+}
+
+/*
+ * Check if the invoice may still be amended, which is the case until it is approved.
+ */
+func (invoice *Invoice) isAmendable() bool {
+	status := invoice.lifecycleStatus()
+	return status == invoiceStatusDraft || status == invoiceStatusSubmitted || status == invoiceStatusDisputed
+}
+
+/*
+ * Function to correct the amount or the lines of an invoice which is not approved yet, either party may amend it.
+ * The prior version is kept in the history of the invoice, the amendment is recorded with the caller and the reason.
+ * 1st - invoice #, 2nd - new invoice amount, or lines and taxes as json for an invoice with lines, 3rd - reason
+ */
+func (cc *Invoice) amendInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 || args[1] == "" || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partySupplier, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if !invoice.isAmendable() {
+		return Error(http.StatusConflict, "Invoice is "+invoice.lifecycleStatus()+", only invoices which are not approved yet can be amended")
+	}
+
+	previousInvoiceAmount := invoice.InvoiceAmount
+
+	if strings.HasPrefix(strings.TrimSpace(args[1]), "{") {
+		// lines and taxes replace the ones of the invoice, material and purchase order stay the same
+		var amendment struct {
+			Lines []InvoiceLine `json:"lines"`
+			Taxes []InvoiceTax  `json:"taxes"`
+		}
+		if err := json.Unmarshal([]byte(args[1]), &amendment); err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid invoice: "+err.Error())
+		}
+
+		invoice.Lines = amendment.Lines
+		invoice.Taxes = amendment.Taxes
+		if err := invoice.summarizeLines(); err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid invoice: "+err.Error())
+		}
+	} else {
+		// amount of an invoice with lines is the sum of its lines
+		if len(invoice.Lines) > 0 {
+			return Error(http.StatusNotAcceptable, "Invoice has lines, amend its lines instead of its amount")
+		}
+
+		if _, err := parseMoney(args[1]); err != nil {
+			return Error(http.StatusNotAcceptable, "Invalid invoice amount: "+err.Error())
+		}
+		invoice.InvoiceAmount = args[1]
+	}
+
+	event, err := newWorkflowEvent(stub, "AMENDED", party, args[2]+" (invoice amount "+previousInvoiceAmount+" -> "+invoice.InvoiceAmount+")")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	invoice.Version = invoice.currentVersion() + 1
+	invoice.History = append(invoice.History, event)
+
+	if err := putInvoice(stub, *invoice); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "Invoice Amended Successsfully!", nil)
+}
+
+/*
+ * Get the version of the invoice, invoices created before versions were introduced are in their first version.
+ */
+func (invoice *Invoice) currentVersion() int {
+	if invoice.Version == 0 {
+		return 1
+	}
+	return invoice.Version
+}
+
+/*
+ * Function to get every version of an invoice with the transaction which wrote it, oldest first.
+ * 1st - invoice #
+ */
+func (cc *Invoice) getInvoiceHistory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	resultsIterator, err := stub.GetHistoryForKey("IN-" + args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	defer resultsIterator.Close()
+
+	versions := []InvoiceVersion{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+
+		version := InvoiceVersion{
+			TxId:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			version.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC().Format(time.RFC3339)
+		}
+		if !modification.IsDelete {
+			var invoice Invoice
+			json.Unmarshal(modification.Value, &invoice)
+			version.Invoice = &invoice
+		}
+
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		return Error(http.StatusNotFound, "Invoice "+args[0]+" not found")
+	}
+
+	versionsInBytes, _ := json.Marshal(versions)
+	return Success(http.StatusOK, "OK", versionsInBytes)
+}
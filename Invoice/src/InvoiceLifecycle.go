@@ -0,0 +1,272 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// states of an invoice, paid and cancelled are final
+const (
+	invoiceStatusDraft         = "Draft"
+	invoiceStatusSubmitted     = "Submitted"
+	invoiceStatusApproved      = "Approved"
+	invoiceStatusDisputed      = "Disputed"
+	invoiceStatusPartiallyPaid = "PartiallyPaid"
+	invoiceStatusPaid          = "Paid"
+	invoiceStatusCancelled     = "Cancelled"
+)
+
+// states an invoice may move to from its current state
+var invoiceTransitions = map[string][]string{
+	invoiceStatusDraft:         {invoiceStatusSubmitted, invoiceStatusCancelled},
+	invoiceStatusSubmitted:     {invoiceStatusApproved, invoiceStatusDisputed, invoiceStatusCancelled},
+	invoiceStatusDisputed:      {invoiceStatusSubmitted, invoiceStatusApproved, invoiceStatusCancelled},
+	invoiceStatusApproved:      {invoiceStatusPartiallyPaid, invoiceStatusPaid},
+	invoiceStatusPartiallyPaid: {invoiceStatusPartiallyPaid, invoiceStatusPaid},
+}
+
+/*
+ * Get the state of the invoice, invoices created before the lifecycle was introduced count as submitted.
+ */
+func (invoice *Invoice) lifecycleStatus() string {
+	if invoice.InvoiceStatus == "" {
+		return invoiceStatusSubmitted
+	}
+	return invoice.InvoiceStatus
+}
+
+/*
+ * Check if credit notes may be settled against the invoice, which is the case once it is approved and until it is paid.
+ */
+func (invoice *Invoice) isApproved() bool {
+	status := invoice.lifecycleStatus()
+	return status == invoiceStatusApproved || status == invoiceStatusPartiallyPaid
+}
+
+/*
+ * Get the amount of the invoice still to be paid, the invoice amount less the part of the credit note deducted from it
+ * and the payments recorded so far. It is negative when a credit note was issued after the invoice was partially paid
+ * and the payments exceed the amount payable.
+ */
+func (invoice *Invoice) openAmount(creditNote *CreditNote) Money {
+	payable, _ := parseMoney(invoice.InvoiceAmount)
+	paid, _ := parseMoney(invoice.PaidAmount)
+	return payable.sub(creditNote.amountFor(*invoice)).sub(paid)
+}
+
+/*
+ * Move the invoice to another state as far as the lifecycle allows it, append the step to its history and write it to blockchain.
+ */
+func changeInvoiceStatus(stub shim.ChaincodeStubInterface, invoice Invoice, status string, party string, comment string) peer.Response {
+	isAllowed := false
+	for _, allowedStatus := range invoiceTransitions[invoice.lifecycleStatus()] {
+		if allowedStatus == status {
+			isAllowed = true
+		}
+	}
+	if !isAllowed {
+		return Error(http.StatusConflict, "Invoice can not be moved from "+invoice.lifecycleStatus()+" to "+status)
+	}
+
+	event, err := newWorkflowEvent(stub, status, party, comment)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	invoice.InvoiceStatus = status
+	invoice.History = append(invoice.History, event)
+
+	if err := putInvoice(stub, invoice); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Read invoice by invoice number and check that the caller acts as one of the given parties.
+ */
+func getInvoiceForParty(stub shim.ChaincodeStubInterface, invoiceNumber string, allowedParties ...string) (*Invoice, string, peer.Response) {
+	invoice, err := getInvoiceById(stub, invoiceNumber)
+	if err != nil {
+		return nil, "", Error(http.StatusInternalServerError, err.Error())
+	}
+	if invoice == nil {
+		return nil, "", Error(http.StatusNotFound, "Invoice "+invoiceNumber+" not found")
+	}
+
+	party, err := getCallerParty(stub, *invoice)
+	if err != nil {
+		return nil, "", Error(http.StatusForbidden, err.Error())
+	}
+
+	for _, allowedParty := range allowedParties {
+		if party == allowedParty {
+			return invoice, party, Success(http.StatusOK, "OK", nil)
+		}
+	}
+
+	return nil, "", Error(http.StatusForbidden, "The "+party+" of the invoice is not allowed to do this")
+}
+
+/*
+ * Function to submit a draft invoice for approval, or to submit a disputed invoice again once it was corrected.
+ * 1st - invoice #
+ * optional: 2nd - comment
+ */
+func (cc *Invoice) submitInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partySupplier, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if response := changeInvoiceStatus(stub, *invoice, invoiceStatusSubmitted, party, optionalArg(args, 1)); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Invoice Submitted Successsfully!", nil)
+}
+
+/*
+ * Function for the buyer to approve a submitted or disputed invoice for payment, once it matched its purchase order
+ * and goods receipts.
+ * 1st - invoice #
+ * optional: 2nd - comment
+ */
+func (cc *Invoice) approveInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	match, err := getInvoiceMatchById(stub, invoice.InvoiceNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := match.checkApproval(*invoice); err != nil {
+		return Error(http.StatusConflict, "Invoice can not be approved: "+err.Error())
+	}
+
+	if response := changeInvoiceStatus(stub, *invoice, invoiceStatusApproved, party, optionalArg(args, 1)); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Invoice Approved Successsfully!", nil)
+}
+
+/*
+ * Function for the buyer to dispute a submitted invoice, the supplier submits it again once it is corrected.
+ * 1st - invoice #, 2nd - reason
+ */
+func (cc *Invoice) disputeInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 || args[1] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if response := changeInvoiceStatus(stub, *invoice, invoiceStatusDisputed, party, args[1]); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Invoice Disputed Successsfully!", nil)
+}
+
+/*
+ * Function to cancel an invoice which is not approved yet. Either party may cancel a draft, once the invoice is submitted
+ * only the buyer may cancel it, so that the supplier can not withdraw an invoice from its share of the delay penalty.
+ * 1st - invoice #, 2nd - reason
+ */
+func (cc *Invoice) cancelInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 || args[1] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partySupplier, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+	if party == partySupplier && invoice.lifecycleStatus() != invoiceStatusDraft {
+		return Error(http.StatusForbidden, "Invoice is "+invoice.lifecycleStatus()+", only the buyer may cancel it once it is submitted")
+	}
+
+	if response := changeInvoiceStatus(stub, *invoice, invoiceStatusCancelled, party, args[1]); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Invoice Cancelled Successsfully!", nil)
+}
+
+/*
+ * Function for the buyer to record a payment of an approved invoice. The invoice is paid once the invoice amount less
+ * the credit note of its delay penalty is paid. Invoices paid in full by a credit note issued after a partial payment
+ * are paid once the credit note is settled.
+ * 1st - invoice #, 2nd - amount paid, 3rd - payment reference e.g. payment document number
+ */
+func (cc *Invoice) recordInvoicePayment(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	amount, err := parseMoney(args[1])
+	if err != nil || amount.sign() <= 0 {
+		return Error(http.StatusNotAcceptable, "Paid amount must be a decimal number greater than 0")
+	}
+
+	invoice, party, response := getInvoiceForParty(stub, args[0], partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	// amount payable is reduced by the part of the delay penalty credited on the invoice
+	creditNote, err := getCreditNoteById(stub, invoice.In_MaterialNumber, invoice.In_PurchaseOrderNumber)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	openAmount := invoice.openAmount(creditNote)
+	if openAmount.sign() < 0 {
+		return Error(http.StatusConflict, "Invoice is overpaid by "+openAmount.neg().format(moneyDecimalPlaces)+" after the credit note, no payment is open")
+	}
+	if openAmount.sign() == 0 {
+		return Error(http.StatusConflict, "No payment is open, the rest of the invoice is deducted by the credit note")
+	}
+	if amount.cmp(openAmount) > 0 {
+		return Error(http.StatusNotAcceptable, "Paid amount exceeds the open amount "+openAmount.format(moneyDecimalPlaces))
+	}
+
+	status := invoiceStatusPartiallyPaid
+	if amount.cmp(openAmount) == 0 {
+		status = invoiceStatusPaid
+	}
+
+	paid, _ := parseMoney(invoice.PaidAmount)
+	invoice.PaidAmount = paid.add(amount).String()
+	if response := changeInvoiceStatus(stub, *invoice, status, party, "Payment "+args[2]+" of "+amount.format(moneyDecimalPlaces)); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Invoice Payment Recorded Successsfully!", nil)
+}
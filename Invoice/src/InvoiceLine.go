@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// InvoiceLine is one line of an invoice posted by the ERP. Its net amount is quantity times unit price,
+// the tax is charged on top of it.
+type InvoiceLine struct {
+	LineNumber     string `json:"lineNumber"`
+	MaterialNumber string `json:"materialNumber"`
+	Quantity       Money  `json:"quantity"`
+	UnitPrice      Money  `json:"unitPrice"`
+	TaxCode        string `json:"taxCode"`
+	TaxAmount      Money  `json:"taxAmount"`
+}
+
+// InvoiceTax is the tax of an invoice for one tax code, summed up over the lines with that code.
+type InvoiceTax struct {
+	TaxCode       string `json:"taxCode"`
+	TaxableAmount Money  `json:"taxableAmount"`
+	TaxAmount     Money  `json:"taxAmount"`
+}
+
+/*
+ * Get the net amount of the line.
+ */
+func (line InvoiceLine) netAmount() Money {
+	return line.Quantity.mul(line.UnitPrice.rat())
+}
+
+/*
+ * Get the amount delay penalties are calculated on, the net amount of the lines or the invoice amount of invoices
+ * created without lines.
+ */
+func (invoice *Invoice) netAmount() Money {
+	if len(invoice.Lines) == 0 {
+		invoiceAmount, _ := parseMoney(invoice.InvoiceAmount)
+		return invoiceAmount
+	}
+
+	netAmount := Money{}
+	for _, line := range invoice.Lines {
+		netAmount = netAmount.add(line.netAmount())
+	}
+	return netAmount
+}
+
+/*
+ * Get the lines the delay penalty is calculated on, an invoice created without lines counts as one line of its amount.
+ */
+func (invoice *Invoice) penaltyLines() []InvoiceLine {
+	if len(invoice.Lines) > 0 {
+		return invoice.Lines
+	}
+
+	quantity, _ := parseMoney("1")
+	return []InvoiceLine{{
+		LineNumber:     "1",
+		MaterialNumber: invoice.In_MaterialNumber,
+		Quantity:       quantity,
+		UnitPrice:      invoice.netAmount(),
+	}}
+}
+
+/*
+ * Parse invoice with lines and taxes passed as json.
+ */
+func parseInvoiceDocument(invoiceInJson string) (Invoice, error) {
+	var invoice Invoice
+	if err := json.Unmarshal([]byte(invoiceInJson), &invoice); err != nil {
+		return invoice, err
+	}
+
+	if invoice.In_MaterialNumber == "" || invoice.In_PurchaseOrderNumber == "" {
+		return invoice, fmt.Errorf("in_MaterialNumber and in_PurchaseOrderNumber are mandatory")
+	}
+
+	err := invoice.summarizeLines()
+	return invoice, err
+}
+
+/*
+ * Validate lines and taxes of an invoice and set its net, tax and invoice amount from the lines. Lines without material
+ * are lines of the invoice's material, taxes are summed up from the lines when they are not passed, otherwise they have
+ * to match the lines. Invoice amount is the gross amount of the lines.
+ */
+func (invoice *Invoice) summarizeLines() error {
+	if len(invoice.Lines) == 0 {
+		return fmt.Errorf("at least one line is mandatory")
+	}
+
+	// tax per tax code as summed up from the lines
+	taxes := []InvoiceTax{}
+	taxIndex := map[string]int{}
+	netAmount := Money{}
+	taxAmount := Money{}
+
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		if line.LineNumber == "" {
+			line.LineNumber = fmt.Sprintf("%d", i+1)
+		}
+		if line.MaterialNumber == "" {
+			line.MaterialNumber = invoice.In_MaterialNumber
+		}
+		if line.Quantity.sign() <= 0 {
+			return fmt.Errorf("line %s: quantity must be greater than 0", line.LineNumber)
+		}
+		if line.UnitPrice.sign() < 0 || line.TaxAmount.sign() < 0 {
+			return fmt.Errorf("line %s: unitPrice and taxAmount must not be negative", line.LineNumber)
+		}
+		if line.TaxAmount.sign() > 0 && line.TaxCode == "" {
+			return fmt.Errorf("line %s: taxCode is mandatory for taxAmount", line.LineNumber)
+		}
+
+		netAmount = netAmount.add(line.netAmount())
+		taxAmount = taxAmount.add(line.TaxAmount)
+
+		if line.TaxCode == "" {
+			continue
+		}
+		if _, ok := taxIndex[line.TaxCode]; !ok {
+			taxIndex[line.TaxCode] = len(taxes)
+			taxes = append(taxes, InvoiceTax{TaxCode: line.TaxCode})
+		}
+		tax := &taxes[taxIndex[line.TaxCode]]
+		tax.TaxableAmount = tax.TaxableAmount.add(line.netAmount())
+		tax.TaxAmount = tax.TaxAmount.add(line.TaxAmount)
+	}
+
+	// tax breakdown of the ERP has to add up to the lines
+	if len(invoice.Taxes) > 0 {
+		if len(invoice.Taxes) != len(taxes) {
+			return fmt.Errorf("taxes must list every tax code of the lines exactly once")
+		}
+		for _, tax := range invoice.Taxes {
+			i, ok := taxIndex[tax.TaxCode]
+			if !ok || tax.TaxAmount.cmp(taxes[i].TaxAmount) != 0 {
+				return fmt.Errorf("tax %s does not match the tax amount of the lines", tax.TaxCode)
+			}
+		}
+	}
+
+	invoice.Taxes = taxes
+	invoice.NetAmount = netAmount.String()
+	invoice.TaxAmount = taxAmount.String()
+	invoice.InvoiceAmount = netAmount.add(taxAmount).String()
+
+	return nil
+}
+
+/*
+ * Evaluate status and penalty of the delivery of the invoice lines of a material on the as-of date. Every line is
+ * evaluated on its net amount, so that no penalty is charged on tax. The sum is capped as agreed in the contract on
+ * the net amount of all lines before an approved waiver is applied.
+ */
+func evaluateInvoiceLines(lines []InvoiceLine, terms PenaltyTerms, waiver *PenaltyWaiver, asOfDate time.Time, expectedDate string, actualDate string, delayReason string) delayEvaluation {
+	var total penaltyTotal
+	var evaluation delayEvaluation
+	netAmount := Money{}
+
+	for _, line := range lines {
+		netAmount = netAmount.add(line.netAmount())
+
+		// status is the same for every line, they are delivered together
+		evaluation = evaluateDelay(line.netAmount(), terms, nil, asOfDate, expectedDate, actualDate, delayReason)
+		total.add(evaluation)
+	}
+
+	// penalty of a delayed delivery is pending until it is delivered
+	if evaluation.delayPenalty != "-" {
+		total.applyTo(&evaluation, netAmount, terms, waiver)
+	}
+
+	return evaluation
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// results of matching an invoice against purchase order and goods receipts
+const (
+	matchStatusMatched    = "MATCHED"
+	matchStatusMismatched = "MISMATCHED"
+)
+
+// PurchaseOrderMaterial is a material of a purchase order with its goods receipts, as returned by the demand chaincode.
+// Received quantity is only known for materials with ordered quantity, line number and unit of measure only for
+// materials ordered on a purchase order line.
+type PurchaseOrderMaterial struct {
+	PurchaseOrderNumber string            `json:"purchaseOrderNumber"`
+	SupplierCode        string            `json:"supplierCode"`
+	MaterialNumber      string            `json:"materialNumber"`
+	ExpectedDate        string            `json:"expectedDate"`
+	ActualDate          string            `json:"actualDate"`
+	DelayReason         string            `json:"delayReason"`
+	LineNumber          string            `json:"lineNumber"`
+	OrderedQuantity     string            `json:"orderedQuantity"`
+	UnitOfMeasure       string            `json:"unitOfMeasure"`
+	UnitPrice           string            `json:"unitPrice"`
+	ReceivedQuantity    string            `json:"receivedQuantity"`
+	IsComplete          bool              `json:"isComplete"`
+	Receipts            []MaterialReceipt `json:"receipts"`
+	Reschedules         []RescheduleEvent `json:"reschedules"`
+}
+
+// InvoiceMatch is the result of the three-way match of an invoice against its purchase order and goods receipts.
+// Quantity variance is the quantity invoiced on all invoices of the material above the received quantity, price
+// variance is the net amount of the invoice above the purchase order's price. Variances are empty when the purchase
+// order has no quantity or price to match against. Only matched invoices are approved, an amended invoice has to be
+// matched again.
+type InvoiceMatch struct {
+	InvoiceNumber          string   `json:"invoiceNumber"`
+	InvoiceVersion         int      `json:"invoiceVersion"`
+	Mt_MaterialNumber      string   `json:"mt_MaterialNumber"`
+	Mt_PurchaseOrderNumber string   `json:"mt_PurchaseOrderNumber"`
+	Status                 string   `json:"status"`
+	ReceivedQuantity       string   `json:"receivedQuantity"`
+	InvoicedQuantity       string   `json:"invoicedQuantity"`
+	QuantityVariance       string   `json:"quantityVariance"`
+	ExpectedAmount         string   `json:"expectedAmount"`
+	InvoicedAmount         string   `json:"invoicedAmount"`
+	PriceVariance          string   `json:"priceVariance"`
+	Exceptions             []string `json:"exceptions"`
+	MatchedOn              string   `json:"matchedOn"`
+	IsInvoiceMatchObject   bool     `json:"isInvoiceMatchObject"`
+}
+
+/*
+ * Read match result of an invoice from blockchain, returns nil match when the invoice was not matched yet.
+ */
+func getInvoiceMatchById(stub shim.ChaincodeStubInterface, invoiceNumber string) (*InvoiceMatch, error) {
+	matchInBytes, err := stub.GetState("MT-" + invoiceNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if matchInBytes == nil {
+		return nil, nil
+	}
+
+	var match InvoiceMatch
+	if err := json.Unmarshal(matchInBytes, &match); err != nil {
+		return nil, err
+	}
+
+	return &match, nil
+}
+
+/*
+ * Write match result to blockchain.
+ */
+func putInvoiceMatch(stub shim.ChaincodeStubInterface, match InvoiceMatch) error {
+	match.IsInvoiceMatchObject = true
+
+	// convert to byte
+	matchInBytes, _ := json.Marshal(match)
+
+	// write match result to BC
+	return stub.PutState("MT-"+match.InvoiceNumber, matchInBytes)
+}
+
+/*
+ * Read a material of a purchase order with its goods receipts from the demand chaincode, the response of the demand
+ * chaincode is returned when it fails, e.g. 404 when purchase order or material do not exist.
+ */
+func getPurchaseOrderMaterial(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) (*PurchaseOrderMaterial, peer.Response) {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return nil, Error(http.StatusInternalServerError, err.Error())
+	}
+	if config.DemandChaincodeName == "" {
+		return nil, Error(http.StatusInternalServerError, "demandChaincodeName is missing in chaincode config")
+	}
+
+	response := stub.InvokeChaincode(config.DemandChaincodeName, toChaincodeArgs("getPurchaseOrderMaterial", materialNumber, purchaseOrderNumber), "")
+	if response.Status != http.StatusOK {
+		return nil, response
+	}
+
+	var material PurchaseOrderMaterial
+	if err := json.Unmarshal(response.Payload, &material); err != nil {
+		return nil, Error(http.StatusInternalServerError, "Invalid response of demand chaincode: "+err.Error())
+	}
+
+	return &material, Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Parse a material of a purchase order passed as json by the demand chaincode, which cannot be called back while it
+ * calls this chaincode.
+ */
+func parsePurchaseOrderMaterial(materialInJson string, materialNumber string, purchaseOrderNumber string) (*PurchaseOrderMaterial, error) {
+	var material PurchaseOrderMaterial
+	if err := json.Unmarshal([]byte(materialInJson), &material); err != nil {
+		return nil, err
+	}
+
+	if material.MaterialNumber != materialNumber || material.PurchaseOrderNumber != purchaseOrderNumber {
+		return nil, fmt.Errorf("material %s of purchase order %s was passed for material %s of purchase order %s", material.MaterialNumber, material.PurchaseOrderNumber, materialNumber, purchaseOrderNumber)
+	}
+
+	// expected date is empty until a proposed date is confirmed, actual date until the whole quantity is received
+	if _, err := time.Parse(timeFormat, material.ExpectedDate); material.ExpectedDate != "" && err != nil {
+		return nil, fmt.Errorf("expectedDate must be in format %s", timeFormat)
+	}
+	if _, err := time.Parse(timeFormat, material.ActualDate); material.ActualDate != "" && err != nil {
+		return nil, fmt.Errorf("actualDate must be in format %s", timeFormat)
+	}
+
+	return &material, nil
+}
+
+/*
+ * Get the ordered quantity and receipts the penalty of a material is pro-rated on, nil for materials without ordered
+ * quantity which are delivered at once.
+ */
+func (material PurchaseOrderMaterial) deliveries() (*MaterialDeliveries, error) {
+	if material.OrderedQuantity == "" {
+		return nil, nil
+	}
+
+	deliveries := MaterialDeliveries{
+		OrderedQuantity: material.OrderedQuantity,
+		UnitPrice:       material.UnitPrice,
+		Receipts:        material.Receipts,
+	}
+	if err := deliveries.validate(); err != nil {
+		return nil, err
+	}
+	return &deliveries, nil
+}
+
+/*
+ * Match quantity and price of an invoice against the material of its purchase order. The quantity is matched for
+ * invoices with lines, summed up over all invoices of the material, so that the received quantity is not invoiced twice.
+ */
+func (match *InvoiceMatch) compare(invoice Invoice, invoices []Invoice, material PurchaseOrderMaterial, config ChaincodeConfig) {
+	if invoice.SupplierCode != "" && material.SupplierCode != "" && invoice.SupplierCode != material.SupplierCode {
+		match.Exceptions = append(match.Exceptions, "supplier "+invoice.SupplierCode+" is not the supplier of the purchase order")
+	}
+
+	if len(material.Receipts) == 0 {
+		match.Exceptions = append(match.Exceptions, "no goods receipt recorded for the material")
+	}
+
+	received, receivedErr := parseMoney(material.ReceivedQuantity)
+	match.ReceivedQuantity = material.ReceivedQuantity
+
+	invoiceQuantity := Money{}
+	for _, line := range invoice.Lines {
+		invoiceQuantity = invoiceQuantity.add(line.Quantity)
+	}
+
+	if receivedErr == nil && len(invoice.Lines) > 0 {
+		invoiced := Money{}
+		for _, other := range invoices {
+			for _, line := range other.Lines {
+				invoiced = invoiced.add(line.Quantity)
+			}
+		}
+
+		variance := invoiced.sub(received)
+		match.InvoicedQuantity = invoiced.String()
+		match.QuantityVariance = variance.String()
+		if variance.cmp(received.percent(config.QuantityTolerancePercent)) > 0 {
+			match.Exceptions = append(match.Exceptions, "invoiced quantity "+invoiced.String()+" exceeds received quantity "+received.String())
+		}
+	}
+
+	unitPrice, err := parseMoney(material.UnitPrice)
+	if err != nil {
+		return
+	}
+
+	// invoices without lines are expected to invoice the received quantity
+	expected := Money{}
+	if len(invoice.Lines) > 0 {
+		expected = invoiceQuantity.mul(unitPrice.rat())
+	} else if receivedErr == nil {
+		expected = received.mul(unitPrice.rat())
+	} else {
+		return
+	}
+
+	invoicedAmount := invoice.netAmount()
+	variance := invoicedAmount.sub(expected)
+	match.ExpectedAmount = expected.format(moneyDecimalPlaces)
+	match.InvoicedAmount = invoicedAmount.format(moneyDecimalPlaces)
+	match.PriceVariance = variance.round(moneyDecimalPlaces, roundingModeHalfUp).format(moneyDecimalPlaces)
+	if variance.cmp(expected.percent(config.PriceTolerancePercent)) > 0 {
+		match.Exceptions = append(match.Exceptions, "invoiced amount "+match.InvoicedAmount+" exceeds the purchase order price of "+match.ExpectedAmount)
+	}
+}
+
+/*
+ * Check that the current version of the invoice matched its purchase order and goods receipts, so that it may be approved.
+ */
+func (match *InvoiceMatch) checkApproval(invoice Invoice) error {
+	if match == nil || match.InvoiceVersion != invoice.currentVersion() {
+		return fmt.Errorf("invoice has to be matched against purchase order and goods receipt before it is approved")
+	}
+	if match.Status != matchStatusMatched {
+		return fmt.Errorf("invoice does not match purchase order and goods receipt: %s", strings.Join(match.Exceptions, ", "))
+	}
+	return nil
+}
+
+/*
+ * Function to match an invoice which is not approved yet against its purchase order and goods receipts of the demand
+ * chaincode, either party may match it. The result is stored and decides whether the buyer may approve the invoice.
+ * 1st - invoice #
+ */
+func (cc *Invoice) matchInvoice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	invoice, _, response := getInvoiceForParty(stub, args[0], partySupplier, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if !invoice.isAmendable() {
+		return Error(http.StatusConflict, "Invoice is "+invoice.lifecycleStatus()+", only invoices which are not approved yet can be matched")
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	match := InvoiceMatch{
+		InvoiceNumber:          invoice.InvoiceNumber,
+		InvoiceVersion:         invoice.currentVersion(),
+		Mt_MaterialNumber:      invoice.In_MaterialNumber,
+		Mt_PurchaseOrderNumber: invoice.In_PurchaseOrderNumber,
+		Exceptions:             []string{},
+		MatchedOn:              txTime.Format(timeFormat),
+	}
+
+	// a purchase order or material unknown to the demand chaincode does not match
+	material, response := getPurchaseOrderMaterial(stub, invoice.In_MaterialNumber, invoice.In_PurchaseOrderNumber)
+	if response.Status == http.StatusNotFound {
+		match.Exceptions = append(match.Exceptions, response.Message)
+	} else if response.Status != http.StatusOK {
+		return response
+	} else {
+		invoices, err := getInvoicesForDelivery(stub, invoice.In_MaterialNumber, invoice.In_PurchaseOrderNumber)
+		if err != nil {
+			return Error(http.StatusInternalServerError, err.Error())
+		}
+		match.compare(*invoice, activeInvoices(invoices), *material, config)
+	}
+
+	match.Status = matchStatusMatched
+	if len(match.Exceptions) > 0 {
+		match.Status = matchStatusMismatched
+	}
+
+	if err := putInvoiceMatch(stub, match); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	matchInBytes, _ := json.Marshal(match)
+	if match.Status != matchStatusMatched {
+		return Success(http.StatusOK, "Invoice does not match purchase order and goods receipt", matchInBytes)
+	}
+	return Success(http.StatusOK, "Invoice Matched Successsfully!", matchInBytes)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInvoiceMatchCompare(t *testing.T) {
+	receipts := []MaterialReceipt{{ReceiptNumber: "1", ActualDate: "01/10/2020", ReceivedQuantity: "10"}}
+	material := PurchaseOrderMaterial{SupplierCode: "S1", ReceivedQuantity: "10", UnitPrice: "100", Receipts: receipts}
+	lines := func(quantity string) []InvoiceLine {
+		return []InvoiceLine{{Quantity: mustParseMoney(t, quantity), UnitPrice: mustParseMoney(t, "100")}}
+	}
+
+	tests := []struct {
+		name                 string
+		invoice              Invoice
+		otherInvoices        []Invoice
+		material             PurchaseOrderMaterial
+		config               ChaincodeConfig
+		wantExceptions       string
+		wantPriceVariance    string
+		wantQuantityVariance string
+	}{
+		{
+			name:              "matched without lines",
+			invoice:           Invoice{InvoiceAmount: "1000", SupplierCode: "S1"},
+			material:          material,
+			wantPriceVariance: "0.00",
+		},
+		{
+			name:           "no goods receipt",
+			invoice:        Invoice{InvoiceAmount: "1000"},
+			material:       PurchaseOrderMaterial{SupplierCode: "S1"},
+			wantExceptions: "no goods receipt recorded for the material",
+		},
+		{
+			name:              "other supplier",
+			invoice:           Invoice{InvoiceAmount: "1000", SupplierCode: "S2"},
+			material:          material,
+			wantExceptions:    "supplier S2 is not the supplier of the purchase order",
+			wantPriceVariance: "0.00",
+		},
+		{
+			name:              "price above the purchase order",
+			invoice:           Invoice{InvoiceAmount: "1050"},
+			material:          material,
+			wantExceptions:    "invoiced amount 1050.00 exceeds the purchase order price of 1000.00",
+			wantPriceVariance: "50.00",
+		},
+		{
+			name:              "price within tolerance",
+			invoice:           Invoice{InvoiceAmount: "1050"},
+			material:          material,
+			config:            ChaincodeConfig{PriceTolerancePercent: 5},
+			wantPriceVariance: "50.00",
+		},
+		{
+			name:                 "quantity invoiced twice",
+			invoice:              Invoice{InvoiceNumber: "1", InvoiceAmount: "600", Lines: lines("6")},
+			otherInvoices:        []Invoice{{InvoiceNumber: "2", InvoiceAmount: "600", Lines: lines("6")}},
+			material:             material,
+			wantExceptions:       "invoiced quantity 12 exceeds received quantity 10",
+			wantPriceVariance:    "0.00",
+			wantQuantityVariance: "2",
+		},
+		{
+			name:                 "quantity within tolerance",
+			invoice:              Invoice{InvoiceNumber: "1", InvoiceAmount: "600", Lines: lines("6")},
+			otherInvoices:        []Invoice{{InvoiceNumber: "2", InvoiceAmount: "600", Lines: lines("6")}},
+			material:             material,
+			config:               ChaincodeConfig{QuantityTolerancePercent: 20},
+			wantPriceVariance:    "0.00",
+			wantQuantityVariance: "2",
+		},
+	}
+
+	for _, test := range tests {
+		var match InvoiceMatch
+		match.compare(test.invoice, append([]Invoice{test.invoice}, test.otherInvoices...), test.material, test.config)
+
+		if got := strings.Join(match.Exceptions, ", "); got != test.wantExceptions {
+			t.Errorf("%s: exceptions = %q, want %q", test.name, got, test.wantExceptions)
+		}
+		if match.PriceVariance != test.wantPriceVariance {
+			t.Errorf("%s: price variance = %q, want %q", test.name, match.PriceVariance, test.wantPriceVariance)
+		}
+		if match.QuantityVariance != test.wantQuantityVariance {
+			t.Errorf("%s: quantity variance = %q, want %q", test.name, match.QuantityVariance, test.wantQuantityVariance)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// rounding modes which can be agreed in a penalty contract
+const (
+	roundingModeHalfUp   = "HALF_UP"
+	roundingModeHalfEven = "HALF_EVEN"
+	roundingModeUp       = "UP"
+	roundingModeDown     = "DOWN"
+)
+
+// decimal places money amounts are rounded to
+const moneyDecimalPlaces = 2
+
+// plain decimal notation accepted for amounts, e.g. 1500 or -12.75
+var moneyPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Money is an exact decimal amount. Arithmetic is done on rationals so that no precision is lost before
+// the result is rounded explicitly. In JSON it is written as a decimal string, numbers are accepted as well.
+type Money struct {
+	value *big.Rat
+}
+
+/*
+ * Parse amount in plain decimal notation.
+ */
+func parseMoney(amount string) (Money, error) {
+	if !moneyPattern.MatchString(amount) {
+		return Money{}, fmt.Errorf("%q is not a decimal amount", amount)
+	}
+
+	value, _ := new(big.Rat).SetString(amount)
+	return Money{value: value}, nil
+}
+
+/*
+ * Convert a rate given as float64, e.g. a percentage, to an exact decimal using its shortest representation,
+ * so that 0.1 is 1/10 and not the nearest binary fraction.
+ */
+func decimalFromFloat(rate float64) *big.Rat {
+	value, _ := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	return value
+}
+
+func (m Money) rat() *big.Rat {
+	if m.value == nil {
+		return new(big.Rat)
+	}
+	return m.value
+}
+
+func (m Money) add(other Money) Money {
+	return Money{value: new(big.Rat).Add(m.rat(), other.rat())}
+}
+
+func (m Money) sub(other Money) Money {
+	return Money{value: new(big.Rat).Sub(m.rat(), other.rat())}
+}
+
+func (m Money) neg() Money {
+	return Money{value: new(big.Rat).Neg(m.rat())}
+}
+
+func (m Money) mul(factor *big.Rat) Money {
+	return Money{value: new(big.Rat).Mul(m.rat(), factor)}
+}
+
+/*
+ * Get the given percentage of the amount, unrounded.
+ */
+func (m Money) percent(percentage float64) Money {
+	return m.mul(new(big.Rat).Quo(decimalFromFloat(percentage), big.NewRat(100, 1)))
+}
+
+func (m Money) cmp(other Money) int {
+	return m.rat().Cmp(other.rat())
+}
+
+func (m Money) sign() int {
+	return m.rat().Sign()
+}
+
+/*
+ * Round amount to the given decimal places with one of the rounding modes, unknown modes round half up.
+ */
+func (m Money) round(places int, mode string) Money {
+	return Money{value: roundDecimal(m.rat(), places, mode)}
+}
+
+/*
+ * Round a decimal to the given decimal places with one of the rounding modes, unknown modes round half up.
+ * The demand chaincode rounds its totals with the same implementation.
+ */
+func roundDecimal(value *big.Rat, places int, mode string) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(scale))
+
+	// quotient is truncated towards zero, remainder decides whether to round away from zero
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	if remainder.Sign() != 0 {
+		twiceRemainder := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1)
+		half := twiceRemainder.Cmp(scaled.Denom())
+
+		awayFromZero := false
+		switch mode {
+		case roundingModeUp:
+			awayFromZero = true
+		case roundingModeDown:
+			awayFromZero = false
+		case roundingModeHalfEven:
+			awayFromZero = half > 0 || (half == 0 && quotient.Bit(0) == 1)
+		default:
+			awayFromZero = half >= 0
+		}
+
+		if awayFromZero {
+			quotient.Add(quotient, big.NewInt(int64(scaled.Num().Sign())))
+		}
+	}
+
+	return new(big.Rat).SetFrac(quotient, scale)
+}
+
+/*
+ * Format amount with exactly the given decimal places, the amount is expected to be rounded already.
+ */
+func (m Money) format(places int) string {
+	return m.rat().FloatString(places)
+}
+
+/*
+ * Format amount in plain decimal notation with as many decimal places as needed to be exact.
+ */
+func (m Money) String() string {
+	scaled := new(big.Rat).Set(m.rat())
+	places := 0
+	for !scaled.IsInt() && places < 32 {
+		scaled.Mul(scaled, big.NewRat(10, 1))
+		places++
+	}
+	return m.format(places)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var amount string
+	if err := json.Unmarshal(data, &amount); err != nil {
+		// amounts written as json number
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		amount = number.String()
+	}
+
+	if amount == "" {
+		*m = Money{}
+		return nil
+	}
+
+	parsed, err := parseMoney(amount)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
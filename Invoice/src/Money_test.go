@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+// parse an amount of a test table, it is expected to be valid
+func mustParseMoney(t *testing.T, amount string) Money {
+	t.Helper()
+	money, err := parseMoney(amount)
+	if err != nil {
+		t.Fatalf("parseMoney(%q): %v", amount, err)
+	}
+	return money
+}
+
+func TestMoneyRound(t *testing.T) {
+	tests := []struct {
+		amount string
+		places int
+		mode   string
+		want   string
+	}{
+		{"1.005", 2, roundingModeHalfUp, "1.01"},
+		{"1.004", 2, roundingModeHalfUp, "1.00"},
+		{"-1.005", 2, roundingModeHalfUp, "-1.01"},
+		{"1.005", 2, roundingModeHalfEven, "1.00"},
+		{"1.015", 2, roundingModeHalfEven, "1.02"},
+		{"1.0051", 2, roundingModeHalfEven, "1.01"},
+		{"2.5", 0, roundingModeHalfEven, "2"},
+		{"3.5", 0, roundingModeHalfEven, "4"},
+		{"1.001", 2, roundingModeUp, "1.01"},
+		{"-1.001", 2, roundingModeUp, "-1.01"},
+		{"1.009", 2, roundingModeDown, "1.00"},
+		{"-1.009", 2, roundingModeDown, "-1.00"},
+		{"1.2", 2, roundingModeDown, "1.20"},
+		{"1.005", 2, "UNKNOWN", "1.01"},
+		{"0", 2, roundingModeUp, "0.00"},
+	}
+
+	for _, test := range tests {
+		got := mustParseMoney(t, test.amount).round(test.places, test.mode).format(test.places)
+		if got != test.want {
+			t.Errorf("round(%s, %d, %s) = %s, want %s", test.amount, test.places, test.mode, got, test.want)
+		}
+	}
+}
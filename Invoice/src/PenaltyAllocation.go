@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math/big"
+)
+
+// PenaltyAllocation is the part of the delay penalty of a material allocated to one of its invoices.
+type PenaltyAllocation struct {
+	InvoiceNumber string `json:"invoiceNumber"`
+	Amount        Money  `json:"amount"`
+}
+
+// invoice of a material with the part of the material's delay penalty allocated to it
+type allocatedInvoice struct {
+	InvoiceNumber string        `json:"invoiceNumber"`
+	InvoiceStatus string        `json:"invoiceStatus"`
+	InvoiceAmount string        `json:"invoiceAmount"`
+	NetAmount     string        `json:"netAmount"`
+	TaxAmount     string        `json:"taxAmount"`
+	PaidAmount    string        `json:"paidAmount"`
+	DelayPenalty  string        `json:"delayPenalty"`
+	Lines         []InvoiceLine `json:"lines"`
+	Taxes         []InvoiceTax  `json:"taxes"`
+}
+
+/*
+ * Get the invoices of a material which bear its delay penalty, cancelled invoices do not.
+ */
+func activeInvoices(invoices []Invoice) []Invoice {
+	active := []Invoice{}
+	for _, invoice := range invoices {
+		if invoice.lifecycleStatus() != invoiceStatusCancelled {
+			active = append(active, invoice)
+		}
+	}
+	return active
+}
+
+/*
+ * Get the state of all invoices of a material, Mixed when they are in different states.
+ */
+func deliveryInvoiceStatus(invoices []Invoice) string {
+	status := ""
+	for _, invoice := range invoices {
+		if status != "" && status != invoice.lifecycleStatus() {
+			return "Mixed"
+		}
+		status = invoice.lifecycleStatus()
+	}
+	return status
+}
+
+/*
+ * Allocate the penalty of a material across its invoices in proportion to their net amounts. Shares are rounded
+ * with the contract's rounding mode, the last invoice takes the rounding difference so that the shares add up.
+ */
+func allocatePenalty(penalty Money, invoices []Invoice, roundingMode string) []PenaltyAllocation {
+	allocations := []PenaltyAllocation{}
+
+	totalNetAmount := Money{}
+	for _, invoice := range invoices {
+		totalNetAmount = totalNetAmount.add(invoice.netAmount())
+	}
+
+	allocated := Money{}
+	for i, invoice := range invoices {
+		share := penalty.sub(allocated)
+		if i < len(invoices)-1 {
+			share = Money{}
+			if totalNetAmount.sign() != 0 {
+				share = penalty.mul(new(big.Rat).Quo(invoice.netAmount().rat(), totalNetAmount.rat())).round(moneyDecimalPlaces, roundingMode)
+			}
+		}
+		allocated = allocated.add(share)
+
+		allocations = append(allocations, PenaltyAllocation{InvoiceNumber: invoice.InvoiceNumber, Amount: share})
+	}
+
+	return allocations
+}
+
+/*
+ * Get the amount allocated to an invoice, zero when nothing was allocated to it.
+ */
+func allocatedAmount(allocations []PenaltyAllocation, invoiceNumber string) Money {
+	for _, allocation := range allocations {
+		if allocation.InvoiceNumber == invoiceNumber {
+			return allocation.Amount
+		}
+	}
+	return Money{}
+}
+
+/*
+ * List the invoices of a material with the penalty allocated to them, the penalty stays pending as long as it is pending
+ * for the material.
+ */
+func allocateToInvoices(invoices []Invoice, evaluation delayEvaluation, roundingMode string) []allocatedInvoice {
+	allocations := allocatePenalty(evaluation.penalty, activeInvoices(invoices), roundingMode)
+
+	allocatedInvoices := []allocatedInvoice{}
+	for _, invoice := range invoices {
+		delayPenalty := allocatedAmount(allocations, invoice.InvoiceNumber).format(moneyDecimalPlaces)
+		if evaluation.delayPenalty == "-" && invoice.lifecycleStatus() != invoiceStatusCancelled {
+			delayPenalty = "-"
+		}
+
+		lines := invoice.Lines
+		if lines == nil {
+			lines = []InvoiceLine{}
+		}
+		taxes := invoice.Taxes
+		if taxes == nil {
+			taxes = []InvoiceTax{}
+		}
+
+		allocatedInvoices = append(allocatedInvoices, allocatedInvoice{
+			InvoiceNumber: invoice.InvoiceNumber,
+			InvoiceStatus: invoice.lifecycleStatus(),
+			InvoiceAmount: invoice.InvoiceAmount,
+			NetAmount:     invoice.netAmount().String(),
+			TaxAmount:     invoice.TaxAmount,
+			PaidAmount:    invoice.PaidAmount,
+			DelayPenalty:  delayPenalty,
+			Lines:         lines,
+			Taxes:         taxes,
+		})
+	}
+
+	return allocatedInvoices
+}
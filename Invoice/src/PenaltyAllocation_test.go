@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllocatePenalty(t *testing.T) {
+	quantity, unitPrice := mustParseMoney(t, "2"), mustParseMoney(t, "150")
+
+	tests := []struct {
+		name         string
+		penalty      string
+		invoices     []Invoice
+		roundingMode string
+		want         string
+	}{
+		{"no invoices", "100", []Invoice{}, roundingModeHalfUp, ""},
+		{"single invoice", "100", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "500"}}, roundingModeHalfUp, "1=100.00"},
+		{"by net amount", "100", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "600"}, {InvoiceNumber: "2", InvoiceAmount: "400"}}, roundingModeHalfUp, "1=60.00 2=40.00"},
+		{"last invoice takes the difference", "100", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "100"}, {InvoiceNumber: "2", InvoiceAmount: "100"}, {InvoiceNumber: "3", InvoiceAmount: "100"}}, roundingModeHalfUp, "1=33.33 2=33.33 3=33.34"},
+		{"rounded half up", "10.01", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "100"}, {InvoiceNumber: "2", InvoiceAmount: "100"}}, roundingModeHalfUp, "1=5.01 2=5.00"},
+		{"rounded down", "10.01", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "100"}, {InvoiceNumber: "2", InvoiceAmount: "100"}}, roundingModeDown, "1=5.00 2=5.01"},
+		{"without net amount", "10", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "0"}, {InvoiceNumber: "2", InvoiceAmount: "0"}}, roundingModeHalfUp, "1=0.00 2=10.00"},
+		{"net amount of the lines", "40", []Invoice{{InvoiceNumber: "1", InvoiceAmount: "357", Lines: []InvoiceLine{{Quantity: quantity, UnitPrice: unitPrice}}}, {InvoiceNumber: "2", InvoiceAmount: "100"}}, roundingModeHalfUp, "1=30.00 2=10.00"},
+	}
+
+	for _, test := range tests {
+		allocations := allocatePenalty(mustParseMoney(t, test.penalty), test.invoices, test.roundingMode)
+
+		shares := []string{}
+		for _, allocation := range allocations {
+			shares = append(shares, allocation.InvoiceNumber+"="+allocation.Amount.format(moneyDecimalPlaces))
+		}
+		if got := strings.Join(shares, " "); got != test.want {
+			t.Errorf("%s: allocations = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
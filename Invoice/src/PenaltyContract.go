@@ -0,0 +1,716 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// penalty modes of a contract, stepped looks up the percentage in the schedule tiers while
+// accruing charges the daily rate for every day of delay
+const (
+	penaltyModeStepped  = "STEPPED"
+	penaltyModeAccruing = "ACCRUING"
+)
+
+// PenaltyContract holds the delay clauses a supplier signed for a period of time. Every renewal is
+// stored as a new contract version, EffectiveTo is empty as long as the version is not superseded.
+// BuyerRescheduleResetsBaseline measures delays from the new date when the buyer reschedules a delivery.
+// The contract is bound to the versions of its schedules and calendar which were in force when it was agreed.
+type PenaltyContract struct {
+	ContractId                    string             `json:"contractId"`
+	SupplierCode                  string             `json:"supplierCode"`
+	Version                       int                `json:"version"`
+	PreviousContractId            string             `json:"previousContractId"`
+	EffectiveFrom                 string             `json:"effectiveFrom"`
+	EffectiveTo                   string             `json:"effectiveTo"`
+	ScheduleId                    string             `json:"scheduleId"`
+	ScheduleVersion               int                `json:"scheduleVersion"`
+	IncentiveScheduleId           string             `json:"incentiveScheduleId"`
+	IncentiveScheduleVersion      int                `json:"incentiveScheduleVersion"`
+	BusinessDaysOnly              bool               `json:"businessDaysOnly"`
+	CalendarId                    string             `json:"calendarId"`
+	CalendarVersion               int                `json:"calendarVersion"`
+	GracePeriodDays               float64            `json:"gracePeriodDays"`
+	MaxPenaltyAmount              Money              `json:"maxPenaltyAmount"`
+	MaxPenaltyPercent             float64            `json:"maxPenaltyPercent"`
+	PenaltyMode                   string             `json:"penaltyMode"`
+	DailyRatePercent              float64            `json:"dailyRatePercent"`
+	RoundingMode                  string             `json:"roundingMode"`
+	Exemptions                    []PenaltyExemption `json:"exemptions"`
+	BuyerRescheduleResetsBaseline bool               `json:"buyerRescheduleResetsBaseline"`
+	IsPenaltyContractObject       bool               `json:"isPenaltyContractObject"`
+}
+
+// PenaltyTerms are the contract, schedules and calendar a delay penalty or early delivery bonus is calculated with.
+// Contract is nil when the default schedule applies, Calendar is nil when every day counts as delay and
+// Incentive is nil when early deliveries are not rewarded.
+type PenaltyTerms struct {
+	Contract  *PenaltyContract
+	Schedule  PenaltySchedule
+	Incentive *PenaltySchedule
+	Calendar  *Calendar
+}
+
+/*
+ * Count delay in days between expected and actual date as agreed in the terms.
+ */
+func (terms PenaltyTerms) delayDays(expectedDate time.Time, actualDate time.Time) float64 {
+	return countDelayDays(expectedDate, actualDate, terms.Calendar)
+}
+
+/*
+ * Get penalty percentage for a delay in days, from the schedule tiers or the daily rate depending on the contract's mode.
+ */
+func (terms PenaltyTerms) percentageForDelay(days float64) *big.Rat {
+	if terms.Contract != nil && terms.Contract.PenaltyMode == penaltyModeAccruing {
+		if days <= 0 {
+			return new(big.Rat)
+		}
+		return new(big.Rat).Mul(decimalFromFloat(terms.Contract.DailyRatePercent), decimalFromFloat(days))
+	}
+
+	return decimalFromFloat(terms.Schedule.percentageForDelay(days))
+}
+
+/*
+ * Get rounding mode agreed in the contract, half up if there is none.
+ */
+func (terms PenaltyTerms) roundingMode() string {
+	if terms.Contract != nil && terms.Contract.RoundingMode != "" {
+		return terms.Contract.RoundingMode
+	}
+	return roundingModeHalfUp
+}
+
+/*
+ * Calculate delay penalty for an invoice amount delivered the given days late. The grace period is deducted from the
+ * delay before the percentage is determined, the capped penalty is limited to the contract's maximum amount and percentage.
+ * Both are rounded to cents with the contract's rounding mode only at the end.
+ */
+func (terms PenaltyTerms) calculatePenalty(invoiceAmount Money, days float64) (uncapped Money, capped Money) {
+	if terms.Contract != nil {
+		days = days - terms.Contract.GracePeriodDays
+	}
+
+	uncapped = invoiceAmount.mul(new(big.Rat).Quo(terms.percentageForDelay(days), big.NewRat(100, 1)))
+	capped = terms.capPenalty(invoiceAmount, uncapped)
+
+	return uncapped.round(moneyDecimalPlaces, terms.roundingMode()), capped.round(moneyDecimalPlaces, terms.roundingMode())
+}
+
+/*
+ * Limit penalty on an invoice amount to the contract's maximum amount and percentage.
+ */
+func (terms PenaltyTerms) capPenalty(invoiceAmount Money, penalty Money) Money {
+	if terms.Contract == nil {
+		return penalty
+	}
+
+	if terms.Contract.MaxPenaltyAmount.sign() > 0 && penalty.cmp(terms.Contract.MaxPenaltyAmount) > 0 {
+		penalty = terms.Contract.MaxPenaltyAmount
+	}
+
+	if maxByPercent := invoiceAmount.percent(terms.Contract.MaxPenaltyPercent); terms.Contract.MaxPenaltyPercent > 0 && penalty.cmp(maxByPercent) > 0 {
+		penalty = maxByPercent
+	}
+
+	return penalty
+}
+
+/*
+ * Calculate bonus for an invoice amount delivered the given days early from the incentive schedule, whose tiers
+ * are read as days early. The bonus is rounded with the contract's rounding mode and is zero without incentive schedule.
+ */
+func (terms PenaltyTerms) calculateIncentive(invoiceAmount Money, earlyDays float64) Money {
+	if terms.Incentive == nil {
+		return Money{}
+	}
+
+	return invoiceAmount.percent(terms.Incentive.percentageForDelay(earlyDays)).round(moneyDecimalPlaces, terms.roundingMode())
+}
+
+/*
+ * Get exemption agreed in the contract for a delay reason, nil when the reason is not excused.
+ */
+func (terms PenaltyTerms) exemptionFor(reasonCode string) *PenaltyExemption {
+	if terms.Contract == nil || reasonCode == "" {
+		return nil
+	}
+
+	for _, exemption := range terms.Contract.Exemptions {
+		if exemption.ReasonCode == reasonCode {
+			return &exemption
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Waive the part of a penalty the contract exempts for the delay reason. The waived amount is rounded with the
+ * contract's rounding mode, so that waived and remaining penalty add up to the penalty.
+ */
+func (terms PenaltyTerms) applyExemption(penalty Money, reasonCode string) (remaining Money, waived Money, exemption *PenaltyExemption) {
+	exemption = terms.exemptionFor(reasonCode)
+	if exemption == nil {
+		return penalty, Money{}, nil
+	}
+
+	waived = penalty.percent(exemption.WaivedPercent).round(moneyDecimalPlaces, terms.roundingMode())
+	return penalty.sub(waived), waived, exemption
+}
+
+/*
+ * Check if contract is in force on the given date, both effective dates are inclusive.
+ */
+func (contract PenaltyContract) isEffectiveOn(date time.Time) bool {
+	from, _ := time.Parse(timeFormat, contract.EffectiveFrom)
+	if date.Before(from) {
+		return false
+	}
+
+	if contract.EffectiveTo == "" {
+		return true
+	}
+
+	to, _ := time.Parse(timeFormat, contract.EffectiveTo)
+	return !date.After(to)
+}
+
+/*
+ * Check if effective periods of both contracts have at least one day in common.
+ */
+func (contract PenaltyContract) overlaps(other PenaltyContract) bool {
+	otherFrom, _ := time.Parse(timeFormat, other.EffectiveFrom)
+	if contract.isEffectiveOn(otherFrom) {
+		return true
+	}
+
+	from, _ := time.Parse(timeFormat, contract.EffectiveFrom)
+	return other.isEffectiveOn(from)
+}
+
+/*
+ * Validate mandatory fields and effective dates of a contract and that its penalty schedule exists.
+ */
+func validatePenaltyContract(stub shim.ChaincodeStubInterface, contract PenaltyContract) error {
+	if contract.ContractId == "" || contract.SupplierCode == "" || contract.ScheduleId == "" {
+		return fmt.Errorf("contractId, supplierCode and scheduleId are mandatory")
+	}
+
+	from, err := time.Parse(timeFormat, contract.EffectiveFrom)
+	if err != nil {
+		return fmt.Errorf("effectiveFrom must be in format %s", timeFormat)
+	}
+
+	if contract.EffectiveTo != "" {
+		to, err := time.Parse(timeFormat, contract.EffectiveTo)
+		if err != nil {
+			return fmt.Errorf("effectiveTo must be in format %s", timeFormat)
+		}
+		if to.Before(from) {
+			return fmt.Errorf("effectiveTo must not be before effectiveFrom")
+		}
+	}
+
+	if contract.GracePeriodDays < 0 || contract.MaxPenaltyAmount.sign() < 0 {
+		return fmt.Errorf("gracePeriodDays and maxPenaltyAmount must not be negative")
+	}
+
+	if contract.MaxPenaltyPercent < 0 || contract.MaxPenaltyPercent > 100 {
+		return fmt.Errorf("maxPenaltyPercent must be between 0 and 100")
+	}
+
+	switch contract.RoundingMode {
+	case "", roundingModeHalfUp, roundingModeHalfEven, roundingModeUp, roundingModeDown:
+	default:
+		return fmt.Errorf("roundingMode must be one of %s, %s, %s, %s", roundingModeHalfUp, roundingModeHalfEven, roundingModeUp, roundingModeDown)
+	}
+
+	// contracts stored before penalty modes were introduced have no mode and are stepped
+	switch contract.PenaltyMode {
+	case "", penaltyModeStepped:
+	case penaltyModeAccruing:
+		if contract.DailyRatePercent <= 0 || contract.DailyRatePercent > 100 {
+			return fmt.Errorf("dailyRatePercent must be greater than 0 and at most 100 for penalty mode %s", penaltyModeAccruing)
+		}
+	default:
+		return fmt.Errorf("penaltyMode must be %s or %s", penaltyModeStepped, penaltyModeAccruing)
+	}
+
+	schedule, err := getPenaltyScheduleVersion(stub, contract.ScheduleId, contract.ScheduleVersion)
+	if err != nil {
+		return err
+	}
+	if schedule == nil {
+		return fmt.Errorf("penalty schedule %s version %d not found", contract.ScheduleId, contract.ScheduleVersion)
+	}
+
+	if contract.IncentiveScheduleId != "" {
+		incentive, err := getPenaltyScheduleVersion(stub, contract.IncentiveScheduleId, contract.IncentiveScheduleVersion)
+		if err != nil {
+			return err
+		}
+		if incentive == nil {
+			return fmt.Errorf("incentive schedule %s version %d not found", contract.IncentiveScheduleId, contract.IncentiveScheduleVersion)
+		}
+	}
+
+	if err := validatePenaltyExemptions(stub, contract.Exemptions); err != nil {
+		return err
+	}
+
+	if contract.CalendarId != "" {
+		calendar, err := getCalendarVersion(stub, contract.CalendarId, contract.CalendarVersion)
+		if err != nil {
+			return err
+		}
+		if calendar == nil {
+			return fmt.Errorf("calendar %s version %d not found", contract.CalendarId, contract.CalendarVersion)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Bind the contract to the latest versions of its schedules and calendar unless it names the versions agreed on.
+ */
+func (contract *PenaltyContract) pinVersions(stub shim.ChaincodeStubInterface) error {
+	if contract.ScheduleVersion == 0 && contract.ScheduleId != "" {
+		schedule, err := getPenaltyScheduleById(stub, contract.ScheduleId)
+		if err != nil || schedule == nil {
+			return err
+		}
+		contract.ScheduleVersion = schedule.currentVersion()
+	}
+
+	if contract.IncentiveScheduleVersion == 0 && contract.IncentiveScheduleId != "" {
+		incentive, err := getPenaltyScheduleById(stub, contract.IncentiveScheduleId)
+		if err != nil || incentive == nil {
+			return err
+		}
+		contract.IncentiveScheduleVersion = incentive.currentVersion()
+	}
+
+	if contract.CalendarVersion == 0 && contract.CalendarId != "" {
+		calendar, err := getCalendarById(stub, contract.CalendarId)
+		if err != nil || calendar == nil {
+			return err
+		}
+		contract.CalendarVersion = calendar.currentVersion()
+	}
+
+	return nil
+}
+
+/*
+ * Get the version of a schedule or calendar the contract is bound to, contracts agreed before schedules and calendars
+ * were versioned are bound to the first version.
+ */
+func boundVersion(version int) int {
+	if version == 0 {
+		return 1
+	}
+	return version
+}
+
+/*
+ * Read penalty contract from blockchain, returns nil contract when it does not exist.
+ */
+func getPenaltyContractById(stub shim.ChaincodeStubInterface, contractId string) (*PenaltyContract, error) {
+	contractInBytes, err := stub.GetState("PC-" + contractId)
+	if err != nil {
+		return nil, err
+	}
+
+	if contractInBytes == nil {
+		return nil, nil
+	}
+
+	var contract PenaltyContract
+	if err := json.Unmarshal(contractInBytes, &contract); err != nil {
+		return nil, err
+	}
+
+	return &contract, nil
+}
+
+/*
+ * Get all contract versions of a supplier.
+ */
+func getPenaltyContractsForSupplier(stub shim.ChaincodeStubInterface, supplierCode string) ([]PenaltyContract, error) {
+	contractIds, err := getPenaltyContractIdsForSupplier(stub, supplierCode)
+	if err != nil {
+		return nil, err
+	}
+
+	contracts := []PenaltyContract{}
+	for _, contractId := range contractIds {
+		contract, err := getPenaltyContractById(stub, contractId)
+		if err != nil {
+			return nil, err
+		}
+		if contract != nil {
+			contracts = append(contracts, *contract)
+		}
+	}
+
+	return contracts, nil
+}
+
+/*
+ * Read the contract ids of a supplier from its index entry. The entry is read by key, so a transaction adding a contract
+ * of the same supplier concurrently makes this one fail validation, which a rich query would not detect.
+ */
+func getPenaltyContractIdsForSupplier(stub shim.ChaincodeStubInterface, supplierCode string) ([]string, error) {
+	indexKey, err := stub.CreateCompositeKey(penaltyContractIndexName, []string{supplierCode})
+	if err != nil {
+		return nil, err
+	}
+
+	contractIdsInBytes, err := stub.GetState(indexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// contracts created before the index was introduced are only found by a query, the index is written with the next contract
+	if contractIdsInBytes == nil {
+		return queryPenaltyContractIdsForSupplier(stub, supplierCode)
+	}
+
+	var contractIds []string
+	if err := json.Unmarshal(contractIdsInBytes, &contractIds); err != nil {
+		return nil, err
+	}
+
+	return contractIds, nil
+}
+
+/*
+ * Query the contract ids of a supplier, for suppliers without index entry.
+ */
+func queryPenaltyContractIdsForSupplier(stub shim.ChaincodeStubInterface, supplierCode string) ([]string, error) {
+	selector := map[string]interface{}{"isPenaltyContractObject": true, "supplierCode": supplierCode}
+	queryInBytes, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	resultsIterator, err := stub.GetQueryResult(string(queryInBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	contractIds := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var contract PenaltyContract
+		if err := json.Unmarshal(queryResponse.Value, &contract); err != nil {
+			return nil, err
+		}
+		contractIds = append(contractIds, contract.ContractId)
+	}
+
+	return contractIds, nil
+}
+
+/*
+ * Write the contract ids of a supplier to its index entry.
+ */
+func putPenaltyContractIdsForSupplier(stub shim.ChaincodeStubInterface, supplierCode string, contractIds []string) error {
+	indexKey, err := stub.CreateCompositeKey(penaltyContractIndexName, []string{supplierCode})
+	if err != nil {
+		return err
+	}
+
+	contractIdsInBytes, _ := json.Marshal(contractIds)
+	return stub.PutState(indexKey, contractIdsInBytes)
+}
+
+// name of the composite key index of contract ids by supplier
+const penaltyContractIndexName = "supplier~contracts"
+
+/*
+ * Find the contract version of a supplier which is in force on the given date, returns nil contract if there is none.
+ */
+func findPenaltyContract(stub shim.ChaincodeStubInterface, supplierCode string, date time.Time) (*PenaltyContract, error) {
+	contracts, err := getPenaltyContractsForSupplier(stub, supplierCode)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contract := range contracts {
+		if contract.isEffectiveOn(date) {
+			return &contract, nil
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+ * Resolve contract, penalty schedule and calendar which apply to a material of a supplier expected on the given date.
+ * Falls back to the default schedule without contract when supplier or expected date are unknown or no contract is in force.
+ */
+func resolvePenaltyTerms(stub shim.ChaincodeStubInterface, supplierCode string, expectedDate string) (PenaltyTerms, error) {
+	var terms PenaltyTerms
+
+	if expectedDateInDateFormat, err := time.Parse(timeFormat, expectedDate); supplierCode != "" && err == nil {
+		if terms.Contract, err = findPenaltyContract(stub, supplierCode, expectedDateInDateFormat); err != nil {
+			return terms, err
+		}
+	}
+
+	// the latest version of the default schedule applies without contract
+	scheduleId := defaultPenaltyScheduleId
+	scheduleVersion := 0
+	if terms.Contract != nil {
+		scheduleId = terms.Contract.ScheduleId
+		scheduleVersion = boundVersion(terms.Contract.ScheduleVersion)
+	}
+
+	schedule, err := getPenaltyScheduleVersion(stub, scheduleId, scheduleVersion)
+	if err != nil {
+		return terms, err
+	}
+	if schedule == nil {
+		return terms, fmt.Errorf("penalty schedule %s not found", scheduleId)
+	}
+	terms.Schedule = *schedule
+
+	// early deliveries are only rewarded when the contract has an incentive schedule
+	if terms.Contract != nil && terms.Contract.IncentiveScheduleId != "" {
+		if terms.Incentive, err = getPenaltyScheduleVersion(stub, terms.Contract.IncentiveScheduleId, boundVersion(terms.Contract.IncentiveScheduleVersion)); err != nil {
+			return terms, err
+		}
+		if terms.Incentive == nil {
+			return terms, fmt.Errorf("incentive schedule %s not found", terms.Contract.IncentiveScheduleId)
+		}
+	}
+
+	// count business days only, with the contract's calendar or weekends off if it names none
+	if terms.Contract != nil && terms.Contract.BusinessDaysOnly {
+		terms.Calendar = &defaultCalendar
+		if terms.Contract.CalendarId != "" {
+			if terms.Calendar, err = getCalendarVersion(stub, terms.Contract.CalendarId, boundVersion(terms.Contract.CalendarVersion)); err != nil {
+				return terms, err
+			}
+			if terms.Calendar == nil {
+				return terms, fmt.Errorf("calendar %s not found", terms.Contract.CalendarId)
+			}
+		}
+	}
+
+	return terms, nil
+}
+
+/*
+ * Validate contract against the other versions of the supplier and write it to blockchain, adding it to the supplier's index.
+ * Versions written earlier in the same transaction have to be passed as pending, because reads only see the state before the transaction.
+ */
+func putPenaltyContract(stub shim.ChaincodeStubInterface, contract PenaltyContract, pending ...PenaltyContract) peer.Response {
+	if err := validatePenaltyContract(stub, contract); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid penalty contract: "+err.Error())
+	}
+
+	// only one contract version of a supplier can be in force on any day
+	contracts, err := getPenaltyContractsForSupplier(stub, contract.SupplierCode)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	contractIds := []string{}
+	indexed := false
+	for _, other := range contracts {
+		contractIds = append(contractIds, other.ContractId)
+		indexed = indexed || other.ContractId == contract.ContractId
+
+		for _, pendingContract := range pending {
+			if pendingContract.ContractId == other.ContractId {
+				other = pendingContract
+			}
+		}
+
+		if other.ContractId != contract.ContractId && other.overlaps(contract) {
+			return Error(http.StatusConflict, "Effective period overlaps with contract "+other.ContractId)
+		}
+	}
+
+	contract.IsPenaltyContractObject = true
+
+	// convert to byte
+	contractInBytes, _ := json.Marshal(contract)
+
+	// write contract to BC
+	if err := stub.PutState("PC-"+contract.ContractId, contractInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// written as well when the contract is already indexed, to seed the index of suppliers whose contracts predate it
+	if !indexed {
+		contractIds = append(contractIds, contract.ContractId)
+	}
+	if err := putPenaltyContractIdsForSupplier(stub, contract.SupplierCode, contractIds); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", contractInBytes)
+}
+
+/*
+ * Function for a buyer organization to create the first version of a supplier's penalty contract.
+ * 1st - contract as json e.g. {"contractId":"C-100","supplierCode":"S1","effectiveFrom":"01/01/2019","effectiveTo":"","scheduleId":"DEFAULT"}
+ */
+func (cc *Invoice) createPenaltyContract(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain penalty contracts"); response.Status != http.StatusOK {
+		return response
+	}
+
+	var contract PenaltyContract
+	if err := json.Unmarshal([]byte(args[0]), &contract); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid penalty contract: "+err.Error())
+	}
+
+	// Check if contract already exists
+	if existing, err := getPenaltyContractById(stub, contract.ContractId); err != nil || existing != nil {
+		return Error(http.StatusConflict, "Penalty contract "+contract.ContractId+" already exists")
+	}
+
+	contract.Version = 1
+	contract.PreviousContractId = ""
+
+	if contract.PenaltyMode == "" {
+		contract.PenaltyMode = penaltyModeStepped
+	}
+
+	if err := contract.pinVersions(stub); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if response := putPenaltyContract(stub, contract); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Penalty Contract Created Successsfully!", nil)
+}
+
+/*
+ * Function for a buyer organization to renew a penalty contract. The current version ends the day before the new version becomes effective.
+ * 1st - contract id of the current version, 2nd - new version as json, supplier code is taken over from the current version
+ */
+func (cc *Invoice) renewPenaltyContract(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain penalty contracts"); response.Status != http.StatusOK {
+		return response
+	}
+
+	current, err := getPenaltyContractById(stub, args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if current == nil {
+		return Error(http.StatusNotFound, "Penalty contract "+args[0]+" not found")
+	}
+
+	var renewal PenaltyContract
+	if err := json.Unmarshal([]byte(args[1]), &renewal); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid penalty contract: "+err.Error())
+	}
+
+	// Check if new contract id is already taken
+	if existing, err := getPenaltyContractById(stub, renewal.ContractId); err != nil || existing != nil {
+		return Error(http.StatusConflict, "Penalty contract "+renewal.ContractId+" already exists")
+	}
+
+	// renewal has to start while the current version is in force, but not on its first day
+	renewalFrom, err := time.Parse(timeFormat, renewal.EffectiveFrom)
+	if err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid penalty contract: effectiveFrom must be in format "+timeFormat)
+	}
+	currentFrom, _ := time.Parse(timeFormat, current.EffectiveFrom)
+	if !renewalFrom.After(currentFrom) || !current.isEffectiveOn(renewalFrom) {
+		return Error(http.StatusNotAcceptable, "Renewal must become effective while contract "+current.ContractId+" is in force")
+	}
+
+	// close current version
+	current.EffectiveTo = renewalFrom.AddDate(0, 0, -1).Format(timeFormat)
+	if response := putPenaltyContract(stub, *current); response.Status != http.StatusOK {
+		return response
+	}
+
+	renewal.SupplierCode = current.SupplierCode
+	if renewal.PenaltyMode == "" {
+		renewal.PenaltyMode = penaltyModeStepped
+	}
+	renewal.Version = current.Version + 1
+	renewal.PreviousContractId = current.ContractId
+
+	if err := renewal.pinVersions(stub); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if response := putPenaltyContract(stub, renewal, *current); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Penalty Contract Renewed Successsfully!", nil)
+}
+
+/*
+ * Function to get penalty contract by id.
+ */
+func (cc *Invoice) getPenaltyContract(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	contractInBytes, err := stub.GetState("PC-" + args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if contractInBytes == nil {
+		return Error(http.StatusNotFound, "Penalty contract "+args[0]+" not found")
+	}
+
+	return Success(http.StatusOK, "OK", contractInBytes)
+}
+
+/*
+ * Function to get all contract versions of a supplier.
+ */
+func (cc *Invoice) getPenaltyContractsBySupplier(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	contracts, err := getPenaltyContractsForSupplier(stub, args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	contractsInBytes, _ := json.Marshal(contracts)
+	return Success(http.StatusOK, "OK", contractsInBytes)
+}
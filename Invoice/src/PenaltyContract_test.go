@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestCalculatePenalty(t *testing.T) {
+	tests := []struct {
+		name         string
+		contract     *PenaltyContract
+		amount       string
+		days         float64
+		wantUncapped string
+		wantCapped   string
+	}{
+		{"not late", nil, "1000", 0, "0.00", "0.00"},
+		{"first tier", nil, "1000", 1, "50.00", "50.00"},
+		{"first tier upper bound", nil, "1000", 2, "50.00", "50.00"},
+		{"second tier", nil, "1000", 5, "100.00", "100.00"},
+		{"open ended tier", nil, "1000", 10, "200.00", "200.00"},
+		{"within grace period", &PenaltyContract{GracePeriodDays: 2}, "1000", 2, "0.00", "0.00"},
+		{"grace period deducted", &PenaltyContract{GracePeriodDays: 1}, "1000", 3, "50.00", "50.00"},
+		{"capped by amount", &PenaltyContract{MaxPenaltyAmount: mustParseMoney(t, "150")}, "1000", 10, "200.00", "150.00"},
+		{"capped by percent", &PenaltyContract{MaxPenaltyPercent: 12}, "1000", 10, "200.00", "120.00"},
+		{"accruing", &PenaltyContract{PenaltyMode: penaltyModeAccruing, DailyRatePercent: 0.5}, "1000", 3, "15.00", "15.00"},
+		{"accruing not late", &PenaltyContract{PenaltyMode: penaltyModeAccruing, DailyRatePercent: 0.5}, "1000", -3, "0.00", "0.00"},
+		{"rounded half up", nil, "10.10", 1, "0.51", "0.51"},
+		{"rounded half even", &PenaltyContract{RoundingMode: roundingModeHalfEven}, "10.10", 1, "0.50", "0.50"},
+		{"rounded down", &PenaltyContract{RoundingMode: roundingModeDown}, "10.19", 1, "0.50", "0.50"},
+	}
+
+	for _, test := range tests {
+		terms := PenaltyTerms{Contract: test.contract, Schedule: defaultPenaltySchedule()}
+
+		uncapped, capped := terms.calculatePenalty(mustParseMoney(t, test.amount), test.days)
+		if got := uncapped.format(moneyDecimalPlaces); got != test.wantUncapped {
+			t.Errorf("%s: uncapped penalty = %s, want %s", test.name, got, test.wantUncapped)
+		}
+		if got := capped.format(moneyDecimalPlaces); got != test.wantCapped {
+			t.Errorf("%s: capped penalty = %s, want %s", test.name, got, test.wantCapped)
+		}
+	}
+}
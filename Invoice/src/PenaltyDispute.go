@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// states of a penalty dispute, upheld and rejected are final
+const (
+	disputeStatusOpen              = "OPEN"
+	disputeStatusEvidenceSubmitted = "EVIDENCE_SUBMITTED"
+	disputeStatusUnderReview       = "UNDER_REVIEW"
+	disputeStatusUpheld            = "UPHELD"
+	disputeStatusRejected          = "REJECTED"
+)
+
+// DisputeEvidence is a document the supplier submitted to prove the claimed delivery date, e.g. a proof of delivery.
+type DisputeEvidence struct {
+	DocumentReference string `json:"documentReference"`
+	Description       string `json:"description"`
+	SubmittedAt       string `json:"submittedAt"`
+}
+
+// PenaltyDispute is raised by the supplier of an invoice against its delay penalty. While it is open the penalty
+// is frozen on the day it was opened, once upheld the penalty is evaluated with the delivery date the supplier claimed.
+type PenaltyDispute struct {
+	Ds_MaterialNumber      string            `json:"ds_MaterialNumber"`
+	Ds_PurchaseOrderNumber string            `json:"ds_PurchaseOrderNumber"`
+	ClaimedActualDate      string            `json:"claimedActualDate"`
+	Reason                 string            `json:"reason"`
+	Status                 string            `json:"status"`
+	OpenedOn               string            `json:"openedOn"`
+	Evidence               []DisputeEvidence `json:"evidence"`
+	History                []WorkflowEvent   `json:"history"`
+	IsPenaltyDisputeObject bool              `json:"isPenaltyDisputeObject"`
+}
+
+/*
+ * Check if the dispute has not been resolved yet.
+ */
+func (dispute *PenaltyDispute) isOpen() bool {
+	return dispute != nil && dispute.Status != disputeStatusUpheld && dispute.Status != disputeStatusRejected
+}
+
+/*
+ * Get the date and actual delivery date the penalty is evaluated with. An open dispute freezes the penalty on the day
+ * it was opened, an upheld dispute replaces the actual date with the one the supplier claimed.
+ */
+func (dispute *PenaltyDispute) evaluationDates(asOfDate time.Time, actualDate string) (time.Time, string) {
+	if dispute.isOpen() {
+		if openedOn, err := time.Parse(timeFormat, dispute.OpenedOn); err == nil && openedOn.Before(asOfDate) {
+			return openedOn, actualDate
+		}
+	} else if dispute != nil && dispute.Status == disputeStatusUpheld {
+		return asOfDate, dispute.ClaimedActualDate
+	}
+
+	return asOfDate, actualDate
+}
+
+/*
+ * Correct receipts of an upheld dispute, none of them was received later than the supplier claimed.
+ */
+func (dispute *PenaltyDispute) correctDeliveries(deliveries MaterialDeliveries) MaterialDeliveries {
+	if dispute == nil || dispute.Status != disputeStatusUpheld {
+		return deliveries
+	}
+
+	claimedActualDate, _ := time.Parse(timeFormat, dispute.ClaimedActualDate)
+
+	receipts := []MaterialReceipt{}
+	for _, receipt := range deliveries.Receipts {
+		if actualDate, _ := time.Parse(timeFormat, receipt.ActualDate); actualDate.After(claimedActualDate) {
+			receipt.ActualDate = dispute.ClaimedActualDate
+		}
+		receipts = append(receipts, receipt)
+	}
+	deliveries.Receipts = receipts
+
+	return deliveries
+}
+
+/*
+ * Read dispute of the penalty of a material of a purchase order from blockchain, returns nil dispute when none was opened.
+ */
+func getPenaltyDisputeById(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) (*PenaltyDispute, error) {
+	disputeInBytes, err := stub.GetState("DS-" + materialNumber + "-" + purchaseOrderNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if disputeInBytes == nil {
+		return nil, nil
+	}
+
+	var dispute PenaltyDispute
+	if err := json.Unmarshal(disputeInBytes, &dispute); err != nil {
+		return nil, err
+	}
+
+	return &dispute, nil
+}
+
+/*
+ * Append a step to the history of the dispute and write it to blockchain.
+ */
+func putPenaltyDispute(stub shim.ChaincodeStubInterface, dispute PenaltyDispute, action string, party string, comment string) peer.Response {
+	event, err := newWorkflowEvent(stub, action, party, comment)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	dispute.History = append(dispute.History, event)
+
+	dispute.IsPenaltyDisputeObject = true
+
+	// convert to byte
+	disputeInBytes, _ := json.Marshal(dispute)
+
+	// write dispute to BC
+	if err := stub.PutState("DS-"+dispute.Ds_MaterialNumber+"-"+dispute.Ds_PurchaseOrderNumber, disputeInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", disputeInBytes)
+}
+
+/*
+ * Read invoice and dispute addressed by the first two parameters and check that the caller acts as the given party.
+ */
+func getDisputeForParty(stub shim.ChaincodeStubInterface, args []string, requiredParty string) (*PenaltyDispute, peer.Response) {
+	invoices, err := getInvoicesForDelivery(stub, args[0], args[1])
+	if err != nil {
+		return nil, Error(http.StatusInternalServerError, err.Error())
+	}
+	if len(invoices) == 0 {
+		return nil, Error(http.StatusNotFound, "Invoice for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+
+	party, err := getCallerParty(stub, invoices[0])
+	if err != nil {
+		return nil, Error(http.StatusForbidden, err.Error())
+	}
+	if party != requiredParty {
+		return nil, Error(http.StatusForbidden, "Only the "+requiredParty+" of the invoice is allowed to do this")
+	}
+
+	dispute, err := getPenaltyDisputeById(stub, args[0], args[1])
+	if err != nil {
+		return nil, Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return dispute, Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Function for the supplier of an invoice to dispute its delay penalty, which is frozen from the day of the transaction.
+ * 1st - material number, 2nd - purchase order #, 3rd - actual delivery date claimed by the supplier, 4th - reason
+ */
+func (cc *Invoice) openPenaltyDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 4 || args[3] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if _, err := time.Parse(timeFormat, args[2]); err != nil {
+		return Error(http.StatusNotAcceptable, "Claimed actual date must be in format "+timeFormat)
+	}
+
+	dispute, response := getDisputeForParty(stub, args, partySupplier)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	// Check if penalty was disputed already
+	if dispute != nil {
+		return Error(http.StatusConflict, "Penalty dispute already exists with status "+dispute.Status)
+	}
+
+	// the penalty of a settled credit note is deducted already
+	if creditNote, err := getCreditNoteById(stub, args[0], args[1]); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	} else if creditNote != nil && creditNote.Status == creditNoteStatusSettled {
+		return Error(http.StatusConflict, "Credit note of the penalty is settled already")
+	}
+
+	openedOn, err := getEvaluationDate(stub, "")
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	dispute = &PenaltyDispute{
+		Ds_MaterialNumber:      args[0],
+		Ds_PurchaseOrderNumber: args[1],
+		ClaimedActualDate:      args[2],
+		Reason:                 args[3],
+		Status:                 disputeStatusOpen,
+		OpenedOn:               openedOn.Format(timeFormat),
+		Evidence:               []DisputeEvidence{},
+	}
+
+	if response := putPenaltyDispute(stub, *dispute, disputeStatusOpen, partySupplier, args[3]); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Penalty Dispute Opened Successsfully!", nil)
+}
+
+/*
+ * Function for the supplier to submit evidence for an open dispute.
+ * 1st - material number, 2nd - purchase order #, 3rd - document reference e.g. proof of delivery number, 4th - description
+ */
+func (cc *Invoice) submitDisputeEvidence(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 4 || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	dispute, response := getDisputeForParty(stub, args, partySupplier)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if dispute == nil {
+		return Error(http.StatusNotFound, "Penalty dispute for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if !dispute.isOpen() {
+		return Error(http.StatusConflict, "Penalty dispute is already "+dispute.Status)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	dispute.Evidence = append(dispute.Evidence, DisputeEvidence{
+		DocumentReference: args[2],
+		Description:       args[3],
+		SubmittedAt:       txTime.Format(time.RFC3339),
+	})
+
+	// evidence submitted during the review does not take the dispute out of review
+	if dispute.Status == disputeStatusOpen {
+		dispute.Status = disputeStatusEvidenceSubmitted
+	}
+
+	if response := putPenaltyDispute(stub, *dispute, disputeStatusEvidenceSubmitted, partySupplier, args[2]); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Dispute Evidence Submitted Successsfully!", nil)
+}
+
+/*
+ * Function for the buyer to start reviewing an open dispute.
+ * 1st - material number, 2nd - purchase order #, 3rd - comment
+ */
+func (cc *Invoice) reviewPenaltyDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	dispute, response := getDisputeForParty(stub, args, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if dispute == nil {
+		return Error(http.StatusNotFound, "Penalty dispute for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if dispute.Status != disputeStatusOpen && dispute.Status != disputeStatusEvidenceSubmitted {
+		return Error(http.StatusConflict, "Penalty dispute is already "+dispute.Status)
+	}
+
+	dispute.Status = disputeStatusUnderReview
+
+	if response := putPenaltyDispute(stub, *dispute, disputeStatusUnderReview, partyBuyer, args[2]); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusOK, "Penalty Dispute Under Review!", nil)
+}
+
+/*
+ * Function for the buyer to resolve a dispute. An upheld dispute replaces the actual delivery date with the claimed one
+ * for the penalty and adjusts a credit note issued before the dispute was opened, a rejected dispute unfreezes the
+ * penalty as it was.
+ * 1st - material number, 2nd - purchase order #, 3rd - UPHELD or REJECTED, 4th - comment
+ */
+func (cc *Invoice) resolvePenaltyDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 4 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if args[2] != disputeStatusUpheld && args[2] != disputeStatusRejected {
+		return Error(http.StatusNotAcceptable, "Resolution must be "+disputeStatusUpheld+" or "+disputeStatusRejected)
+	}
+
+	dispute, response := getDisputeForParty(stub, args, partyBuyer)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if dispute == nil {
+		return Error(http.StatusNotFound, "Penalty dispute for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if !dispute.isOpen() {
+		return Error(http.StatusConflict, "Penalty dispute is already "+dispute.Status)
+	}
+
+	dispute.Status = args[2]
+
+	if response := putPenaltyDispute(stub, *dispute, args[2], partyBuyer, args[3]); response.Status != http.StatusOK {
+		return response
+	}
+
+	if dispute.Status == disputeStatusUpheld {
+		if response := adjustCreditNoteForDispute(stub, *dispute); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	return Success(http.StatusOK, "Penalty Dispute Resolved Successsfully!", nil)
+}
+
+/*
+ * Function to get the dispute of the penalty of a material of a purchase order with its evidence and history.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *Invoice) getPenaltyDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	disputeInBytes, err := stub.GetState("DS-" + args[0] + "-" + args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if disputeInBytes == nil {
+		return Error(http.StatusNotFound, "Penalty dispute for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+
+	return Success(http.StatusOK, "OK", disputeInBytes)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// id of the schedule used when no contract specific schedule applies
+const defaultPenaltyScheduleId = "DEFAULT"
+
+// PenaltyTier is one band of a penalty schedule. A delay of d days falls into the tier when
+// FromDays < d <= ToDays. ToDays of 0 is only allowed on the last tier and means no upper bound.
+type PenaltyTier struct {
+	FromDays   float64 `json:"fromDays"`
+	ToDays     float64 `json:"toDays"`
+	Percentage float64 `json:"percentage"`
+}
+
+// PenaltySchedule holds the tiers of a penalty or incentive schedule. Tiers are never changed in place, every update
+// is stored as a new version, so that contracts keep the version they were agreed on.
+type PenaltySchedule struct {
+	ScheduleId              string        `json:"scheduleId"`
+	Version                 int           `json:"version"`
+	Tiers                   []PenaltyTier `json:"tiers"`
+	IsPenaltyScheduleObject bool          `json:"isPenaltyScheduleObject"`
+}
+
+/*
+ * Schedule written during Init so that penalty evaluation works out of the box; it holds the
+ * tiers which used to be hardcoded in createInvoiceObject (0-2 days 5%, 2-7 days 10%, >7 days 20%).
+ */
+func defaultPenaltySchedule() PenaltySchedule {
+	return PenaltySchedule{
+		ScheduleId: defaultPenaltyScheduleId,
+		Version:    1,
+		Tiers: []PenaltyTier{
+			{FromDays: 0, ToDays: 2, Percentage: 5},
+			{FromDays: 2, ToDays: 7, Percentage: 10},
+			{FromDays: 7, ToDays: 0, Percentage: 20},
+		},
+		IsPenaltyScheduleObject: true,
+	}
+}
+
+/*
+ * Validate that tiers start at 0 days, are contiguous, do not overlap and only the last one is open ended.
+ */
+func validatePenaltyTiers(tiers []PenaltyTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("penalty schedule must have at least one tier")
+	}
+
+	if tiers[0].FromDays != 0 {
+		return fmt.Errorf("first tier must start at 0 days")
+	}
+
+	for i, tier := range tiers {
+		if tier.Percentage < 0 || tier.Percentage > 100 {
+			return fmt.Errorf("tier %d: percentage must be between 0 and 100", i+1)
+		}
+
+		isLast := i == len(tiers)-1
+
+		// open ended tier is only allowed at the end of the schedule
+		if tier.ToDays == 0 && !isLast {
+			return fmt.Errorf("tier %d: only the last tier can be open ended", i+1)
+		}
+
+		if tier.ToDays != 0 && tier.ToDays <= tier.FromDays {
+			return fmt.Errorf("tier %d: toDays must be greater than fromDays", i+1)
+		}
+
+		// next tier has to start exactly where this one ends
+		if !isLast && tiers[i+1].FromDays != tier.ToDays {
+			return fmt.Errorf("tier %d: must start at %v days, where tier %d ends", i+2, tier.ToDays, i+1)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Get penalty percentage of the tier the given delay in days falls into, 0 if the delay is not covered by any tier.
+ */
+func (schedule PenaltySchedule) percentageForDelay(days float64) float64 {
+	for _, tier := range schedule.Tiers {
+		if days > tier.FromDays && (tier.ToDays == 0 || days <= tier.ToDays) {
+			return tier.Percentage
+		}
+	}
+	return 0
+}
+
+/*
+ * Read penalty schedule from blockchain, returns nil schedule when it does not exist.
+ */
+func getPenaltyScheduleById(stub shim.ChaincodeStubInterface, scheduleId string) (*PenaltySchedule, error) {
+	scheduleInBytes, err := stub.GetState("PS-" + scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduleInBytes == nil {
+		return nil, nil
+	}
+
+	var schedule PenaltySchedule
+	if err := json.Unmarshal(scheduleInBytes, &schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+/*
+ * Get the version of the schedule, schedules created before versions were introduced are in their first version.
+ */
+func (schedule PenaltySchedule) currentVersion() int {
+	if schedule.Version == 0 {
+		return 1
+	}
+	return schedule.Version
+}
+
+/*
+ * Read a version of a penalty schedule from blockchain, the latest version when version is 0. Returns nil schedule
+ * when the schedule or the version does not exist.
+ */
+func getPenaltyScheduleVersion(stub shim.ChaincodeStubInterface, scheduleId string, version int) (*PenaltySchedule, error) {
+	schedule, err := getPenaltyScheduleById(stub, scheduleId)
+	if err != nil || schedule == nil || version == 0 || schedule.currentVersion() == version {
+		return schedule, err
+	}
+
+	scheduleInBytes, err := stub.GetState("PSV-" + scheduleId + "-" + strconv.Itoa(version))
+	if err != nil || scheduleInBytes == nil {
+		return nil, err
+	}
+
+	var scheduleVersion PenaltySchedule
+	if err := json.Unmarshal(scheduleInBytes, &scheduleVersion); err != nil {
+		return nil, err
+	}
+
+	return &scheduleVersion, nil
+}
+
+/*
+ * Validate and write penalty schedule to blockchain, as latest version and under its version number.
+ */
+func putPenaltySchedule(stub shim.ChaincodeStubInterface, schedule PenaltySchedule) peer.Response {
+	if err := validatePenaltyTiers(schedule.Tiers); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid penalty schedule: "+err.Error())
+	}
+
+	schedule.Version = schedule.currentVersion()
+	schedule.IsPenaltyScheduleObject = true
+
+	// convert to byte
+	scheduleInBytes, _ := json.Marshal(schedule)
+
+	// write schedule to BC
+	if err := stub.PutState("PS-"+schedule.ScheduleId, scheduleInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := stub.PutState("PSV-"+schedule.ScheduleId+"-"+strconv.Itoa(schedule.Version), scheduleInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "OK", scheduleInBytes)
+}
+
+/*
+ * Function for a buyer organization to create penalty schedule.
+ * 1st - schedule id, 2nd - tiers as json array e.g. [{"fromDays":0,"toDays":2,"percentage":5},{"fromDays":2,"toDays":0,"percentage":10}]
+ */
+func (cc *Invoice) createPenaltySchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 || args[0] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain penalty schedules"); response.Status != http.StatusOK {
+		return response
+	}
+
+	// Check if schedule already exists
+	if existing, err := getPenaltyScheduleById(stub, args[0]); err != nil || existing != nil {
+		return Error(http.StatusConflict, "Penalty schedule "+args[0]+" already exists")
+	}
+
+	schedule := PenaltySchedule{ScheduleId: args[0], Version: 1}
+	if err := json.Unmarshal([]byte(args[1]), &schedule.Tiers); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid tiers: "+err.Error())
+	}
+
+	if response := putPenaltySchedule(stub, schedule); response.Status != http.StatusOK {
+		return response
+	}
+
+	return Success(http.StatusCreated, "Penalty Schedule Created Successsfully!", nil)
+}
+
+/*
+ * Function for a buyer organization to publish new tiers of an existing penalty schedule as its next version.
+ * Contracts keep the version they were agreed on, evaluations without contract use the latest version.
+ * 1st - schedule id, 2nd - tiers as json array
+ */
+func (cc *Invoice) updatePenaltySchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	if response := checkCallerIsBuyer(stub, "maintain penalty schedules"); response.Status != http.StatusOK {
+		return response
+	}
+
+	schedule, err := getPenaltyScheduleById(stub, args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if schedule == nil {
+		return Error(http.StatusNotFound, "Penalty schedule "+args[0]+" not found")
+	}
+
+	var tiers []PenaltyTier
+	if err := json.Unmarshal([]byte(args[1]), &tiers); err != nil {
+		return Error(http.StatusNotAcceptable, "Invalid tiers: "+err.Error())
+	}
+
+	// schedules created before versions were introduced are kept as their first version
+	if schedule.Version == 0 {
+		if response := putPenaltySchedule(stub, *schedule); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	schedule.Version = schedule.currentVersion() + 1
+	schedule.Tiers = tiers
+
+	return putPenaltySchedule(stub, *schedule)
+}
+
+/*
+ * Function to get penalty schedule by id.
+ * 1st - schedule id
+ * optional: 2nd - version, the latest version if not passed
+ */
+func (cc *Invoice) getPenaltySchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) < 1 || len(args) > 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	version := 0
+	if versionArg := optionalArg(args, 1); versionArg != "" {
+		var err error
+		if version, err = strconv.Atoi(versionArg); err != nil || version <= 0 {
+			return Error(http.StatusNotAcceptable, "Version must be a number greater than 0")
+		}
+	}
+
+	schedule, err := getPenaltyScheduleVersion(stub, args[0], version)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if schedule == nil {
+		return Error(http.StatusNotFound, "Penalty schedule "+args[0]+" not found")
+	}
+
+	scheduleInBytes, _ := json.Marshal(schedule)
+	return Success(http.StatusOK, "OK", scheduleInBytes)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// states of a penalty waiver, a waiver is approved once both buyer and supplier approved it
+const (
+	waiverStatusRequested = "REQUESTED"
+	waiverStatusApproved  = "APPROVED"
+	waiverStatusRejected  = "REJECTED"
+)
+
+// PenaltyWaiver waives a percentage of the delay penalty of the invoice of a material of a purchase order.
+// A rejected waiver can be requested again, its history is kept.
+type PenaltyWaiver struct {
+	Wv_MaterialNumber      string          `json:"wv_MaterialNumber"`
+	Wv_PurchaseOrderNumber string          `json:"wv_PurchaseOrderNumber"`
+	WaivedPercent          float64         `json:"waivedPercent"`
+	Justification          string          `json:"justification"`
+	Status                 string          `json:"status"`
+	BuyerApproved          bool            `json:"buyerApproved"`
+	SupplierApproved       bool            `json:"supplierApproved"`
+	History                []WorkflowEvent `json:"history"`
+	IsPenaltyWaiverObject  bool            `json:"isPenaltyWaiverObject"`
+}
+
+/*
+ * Read waiver of the penalty of a material of a purchase order from blockchain, returns nil waiver when none was requested.
+ */
+func getPenaltyWaiverById(stub shim.ChaincodeStubInterface, materialNumber string, purchaseOrderNumber string) (*PenaltyWaiver, error) {
+	waiverInBytes, err := stub.GetState("WV-" + materialNumber + "-" + purchaseOrderNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if waiverInBytes == nil {
+		return nil, nil
+	}
+
+	var waiver PenaltyWaiver
+	if err := json.Unmarshal(waiverInBytes, &waiver); err != nil {
+		return nil, err
+	}
+
+	return &waiver, nil
+}
+
+/*
+ * Write waiver to blockchain.
+ */
+func putPenaltyWaiver(stub shim.ChaincodeStubInterface, waiver PenaltyWaiver) error {
+	waiver.IsPenaltyWaiverObject = true
+
+	// convert to byte
+	waiverInBytes, _ := json.Marshal(waiver)
+
+	// write waiver to BC
+	return stub.PutState("WV-"+waiver.Wv_MaterialNumber+"-"+waiver.Wv_PurchaseOrderNumber, waiverInBytes)
+}
+
+/*
+ * Append a step to the history of the waiver.
+ */
+func (waiver *PenaltyWaiver) recordEvent(stub shim.ChaincodeStubInterface, action string, party string, comment string) error {
+	event, err := newWorkflowEvent(stub, action, party, comment)
+	if err != nil {
+		return err
+	}
+
+	waiver.History = append(waiver.History, event)
+	return nil
+}
+
+/*
+ * Waive the approved percentage of a penalty, the penalty stays as is while the waiver is not approved.
+ */
+func (waiver *PenaltyWaiver) apply(penalty Money, roundingMode string) (remaining Money, waived Money) {
+	if waiver == nil || waiver.Status != waiverStatusApproved {
+		return penalty, Money{}
+	}
+
+	waived = penalty.percent(waiver.WaivedPercent).round(moneyDecimalPlaces, roundingMode)
+	return penalty.sub(waived), waived
+}
+
+/*
+ * Read invoice and waiver addressed by the first two parameters and the party the caller acts as.
+ */
+func getWaiverWithParty(stub shim.ChaincodeStubInterface, args []string) (*PenaltyWaiver, string, peer.Response) {
+	invoices, err := getInvoicesForDelivery(stub, args[0], args[1])
+	if err != nil {
+		return nil, "", Error(http.StatusInternalServerError, err.Error())
+	}
+	if len(invoices) == 0 {
+		return nil, "", Error(http.StatusNotFound, "Invoice for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+
+	party, err := getCallerParty(stub, invoices[0])
+	if err != nil {
+		return nil, "", Error(http.StatusForbidden, err.Error())
+	}
+
+	waiver, err := getPenaltyWaiverById(stub, args[0], args[1])
+	if err != nil {
+		return nil, "", Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return waiver, party, Success(http.StatusOK, "OK", nil)
+}
+
+/*
+ * Function to request a waiver of the delay penalty of an invoice, either buyer or supplier may request it.
+ * The request counts as approval of the requesting party.
+ * 1st - material number, 2nd - purchase order #, 3rd - percentage of the penalty to waive, 4th - justification
+ */
+func (cc *Invoice) requestPenaltyWaiver(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 4 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	waivedPercent, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || waivedPercent <= 0 || waivedPercent > 100 {
+		return Error(http.StatusNotAcceptable, "Waived percentage must be greater than 0 and at most 100")
+	}
+
+	if args[3] == "" {
+		return Error(http.StatusNotAcceptable, "Justification is mandatory")
+	}
+
+	waiver, party, response := getWaiverWithParty(stub, args)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	// only one waiver can be pending or approved at a time
+	if waiver != nil && waiver.Status != waiverStatusRejected {
+		return Error(http.StatusConflict, "Penalty waiver is already "+waiver.Status)
+	}
+
+	// the penalty of a settled credit note is deducted already
+	creditNote, err := getCreditNoteById(stub, args[0], args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if creditNote != nil && creditNote.Status == creditNoteStatusSettled {
+		return Error(http.StatusConflict, "Credit note is already "+creditNote.Status+", its penalty can not be waived")
+	}
+
+	if waiver == nil {
+		waiver = &PenaltyWaiver{Wv_MaterialNumber: args[0], Wv_PurchaseOrderNumber: args[1]}
+	}
+
+	waiver.WaivedPercent = waivedPercent
+	waiver.Justification = args[3]
+	waiver.Status = waiverStatusRequested
+	waiver.BuyerApproved = party == partyBuyer
+	waiver.SupplierApproved = party == partySupplier
+
+	if err := waiver.recordEvent(stub, waiverStatusRequested, party, args[3]); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := putPenaltyWaiver(stub, *waiver); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusCreated, "Penalty Waiver Requested Successsfully!", nil)
+}
+
+/*
+ * Function to approve a requested waiver on behalf of the caller's party, it is approved when both parties approved it.
+ * An issued credit note is adjusted to the waived penalty then, it can not be approved once the credit note is settled.
+ * 1st - material number, 2nd - purchase order #, 3rd - comment
+ */
+func (cc *Invoice) approvePenaltyWaiver(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	waiver, party, response := getWaiverWithParty(stub, args)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if waiver == nil {
+		return Error(http.StatusNotFound, "Penalty waiver for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if waiver.Status != waiverStatusRequested {
+		return Error(http.StatusConflict, "Penalty waiver is already "+waiver.Status)
+	}
+
+	if (party == partyBuyer && waiver.BuyerApproved) || (party == partySupplier && waiver.SupplierApproved) {
+		return Error(http.StatusConflict, "Penalty waiver is already approved by "+party)
+	}
+
+	if party == partyBuyer {
+		waiver.BuyerApproved = true
+	} else {
+		waiver.SupplierApproved = true
+	}
+
+	action := "APPROVED_BY_" + party
+	if waiver.BuyerApproved && waiver.SupplierApproved {
+		waiver.Status = waiverStatusApproved
+		action = waiverStatusApproved
+	}
+
+	if err := waiver.recordEvent(stub, action, party, args[2]); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := putPenaltyWaiver(stub, *waiver); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if waiver.Status == waiverStatusApproved {
+		if response := adjustCreditNoteForWaiver(stub, *waiver); response.Status != http.StatusOK {
+			return response
+		}
+	}
+
+	return Success(http.StatusOK, "Penalty Waiver Approved Successsfully!", nil)
+}
+
+/*
+ * Function to reject a requested waiver, either party may reject it.
+ * 1st - material number, 2nd - purchase order #, 3rd - reason of the rejection
+ */
+func (cc *Invoice) rejectPenaltyWaiver(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 3 || args[2] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	waiver, party, response := getWaiverWithParty(stub, args)
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if waiver == nil {
+		return Error(http.StatusNotFound, "Penalty waiver for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+	if waiver.Status != waiverStatusRequested {
+		return Error(http.StatusConflict, "Penalty waiver is already "+waiver.Status)
+	}
+
+	waiver.Status = waiverStatusRejected
+
+	if err := waiver.recordEvent(stub, waiverStatusRejected, party, args[2]); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := putPenaltyWaiver(stub, *waiver); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusOK, "Penalty Waiver Rejected Successsfully!", nil)
+}
+
+/*
+ * Function to get the waiver of the penalty of a material of a purchase order with its history.
+ * 1st - material number, 2nd - purchase order #
+ */
+func (cc *Invoice) getPenaltyWaiver(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	waiverInBytes, err := stub.GetState("WV-" + args[0] + "-" + args[1])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if waiverInBytes == nil {
+		return Error(http.StatusNotFound, "Penalty waiver for purchase order "+args[1]+" and material number "+args[0]+" not found")
+	}
+
+	return Success(http.StatusOK, "OK", waiverInBytes)
+}
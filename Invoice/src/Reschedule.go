@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// RescheduleEvent is a change of the confirmed expected date of a material as kept by the demand chaincode, in the
+// order the reschedules happened. RequestedBy is the party which caused the reschedule, the other party confirmed it.
+type RescheduleEvent struct {
+	OldExpectedDate string `json:"oldExpectedDate"`
+	NewExpectedDate string `json:"newExpectedDate"`
+	RequestedBy     string `json:"requestedBy"`
+	MspId           string `json:"mspId"`
+	Reason          string `json:"reason"`
+	RescheduledOn   string `json:"rescheduledOn"`
+}
+
+/*
+ * Get the date delays are measured from. It stays on the originally confirmed date when the supplier reschedules, so
+ * that the supplier can not escape the penalty of its own delay. Reschedules requested by the buyer move it to the new
+ * date only if the contract says so.
+ */
+func (terms PenaltyTerms) penaltyBaselineDate(expectedDate string, reschedules []RescheduleEvent) string {
+	if len(reschedules) == 0 {
+		return expectedDate
+	}
+
+	baselineDate := reschedules[0].OldExpectedDate
+	for _, reschedule := range reschedules {
+		if reschedule.RequestedBy == partyBuyer && terms.Contract != nil && terms.Contract.BuyerRescheduleResetsBaseline {
+			baselineDate = reschedule.NewExpectedDate
+		}
+	}
+	return baselineDate
+}
+
+/*
+ * Resolve the penalty terms of a material on the baseline date its delay is measured from, so that a reschedule into
+ * the period of another contract does not change schedule and calendar of the delay afterwards. The contract in force
+ * on the originally confirmed date decides whether a reschedule of the buyer moves the baseline.
+ */
+func resolveBaselinePenaltyTerms(stub shim.ChaincodeStubInterface, supplierCode string, material PurchaseOrderMaterial) (PenaltyTerms, string, error) {
+	confirmedDate := material.ExpectedDate
+	if len(material.Reschedules) > 0 {
+		confirmedDate = material.Reschedules[0].OldExpectedDate
+	}
+
+	terms, err := resolvePenaltyTerms(stub, supplierCode, confirmedDate)
+	if err != nil {
+		return terms, "", err
+	}
+
+	baselineDate := terms.penaltyBaselineDate(material.ExpectedDate, material.Reschedules)
+	if baselineDate != confirmedDate {
+		terms, err = resolvePenaltyTerms(stub, supplierCode, baselineDate)
+	}
+	return terms, baselineDate, err
+}
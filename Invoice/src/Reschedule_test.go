@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPenaltyBaselineDate(t *testing.T) {
+	supplierReschedule := RescheduleEvent{OldExpectedDate: "01/10/2020", NewExpectedDate: "01/20/2020", RequestedBy: partySupplier}
+	buyerReschedule := RescheduleEvent{OldExpectedDate: "01/20/2020", NewExpectedDate: "01/25/2020", RequestedBy: partyBuyer}
+	resettingContract := &PenaltyContract{BuyerRescheduleResetsBaseline: true}
+
+	tests := []struct {
+		name        string
+		contract    *PenaltyContract
+		reschedules []RescheduleEvent
+		want        string
+	}{
+		{"not rescheduled", nil, nil, "01/30/2020"},
+		{"rescheduled by the supplier", resettingContract, []RescheduleEvent{supplierReschedule}, "01/10/2020"},
+		{"rescheduled by the buyer without contract", nil, []RescheduleEvent{{OldExpectedDate: "01/10/2020", NewExpectedDate: "01/25/2020", RequestedBy: partyBuyer}}, "01/10/2020"},
+		{"rescheduled by the buyer", resettingContract, []RescheduleEvent{{OldExpectedDate: "01/10/2020", NewExpectedDate: "01/25/2020", RequestedBy: partyBuyer}}, "01/25/2020"},
+		{"rescheduled by the buyer after the supplier", resettingContract, []RescheduleEvent{supplierReschedule, buyerReschedule}, "01/25/2020"},
+		{"rescheduled by the supplier after the buyer", resettingContract, []RescheduleEvent{{OldExpectedDate: "01/10/2020", NewExpectedDate: "01/25/2020", RequestedBy: partyBuyer}, {OldExpectedDate: "01/25/2020", NewExpectedDate: "02/05/2020", RequestedBy: partySupplier}}, "01/25/2020"},
+		{"contract keeps the baseline", &PenaltyContract{}, []RescheduleEvent{supplierReschedule, buyerReschedule}, "01/10/2020"},
+	}
+
+	for _, test := range tests {
+		terms := PenaltyTerms{Contract: test.contract}
+		if got := terms.penaltyBaselineDate("01/30/2020", test.reschedules); got != test.want {
+			t.Errorf("%s: baseline date = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// parties of an invoice which take part in waivers and disputes
+const (
+	partyBuyer    = "BUYER"
+	partySupplier = "SUPPLIER"
+)
+
+// Supplier maps a supplier code to the MSP id of the supplier's organization.
+type Supplier struct {
+	SupplierCode     string `json:"supplierCode"`
+	MspId            string `json:"mspId"`
+	IsSupplierObject bool   `json:"isSupplierObject"`
+}
+
+// WorkflowEvent is one step of the history of a waiver or dispute.
+type WorkflowEvent struct {
+	Action    string `json:"action"`
+	Party     string `json:"party"`
+	MspId     string `json:"mspId"`
+	Comment   string `json:"comment"`
+	Timestamp string `json:"timestamp"`
+}
+
+/*
+ * Create history step taken by the caller's organization at transaction time.
+ */
+func newWorkflowEvent(stub shim.ChaincodeStubInterface, action string, party string, comment string) (WorkflowEvent, error) {
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return WorkflowEvent{}, err
+	}
+
+	callerMspId, _ := getCallerMspId(stub)
+
+	return WorkflowEvent{
+		Action:    action,
+		Party:     party,
+		MspId:     callerMspId,
+		Comment:   comment,
+		Timestamp: txTime.Format(time.RFC3339),
+	}, nil
+}
+
+/*
+ * Read supplier from blockchain, returns nil supplier when it is not registered.
+ */
+func getSupplierByCode(stub shim.ChaincodeStubInterface, supplierCode string) (*Supplier, error) {
+	supplierInBytes, err := stub.GetState("SUP-" + supplierCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if supplierInBytes == nil {
+		return nil, nil
+	}
+
+	var supplier Supplier
+	if err := json.Unmarshal(supplierInBytes, &supplier); err != nil {
+		return nil, err
+	}
+
+	return &supplier, nil
+}
+
+/*
+ * Determine whether the client which submitted the transaction acts as buyer or as supplier of the invoice.
+ */
+func getCallerParty(stub shim.ChaincodeStubInterface, invoice Invoice) (string, error) {
+	return getCallerPartyForSupplier(stub, invoice.SupplierCode)
+}
+
+/*
+ * Determine whether the client which submitted the transaction acts as buyer or as the given supplier.
+ */
+func getCallerPartyForSupplier(stub shim.ChaincodeStubInterface, supplierCode string) (string, error) {
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return "", err
+	}
+
+	if checkCallerMsp(stub, config.BuyerMspIds) == nil {
+		return partyBuyer, nil
+	}
+
+	if supplierCode != "" {
+		supplier, err := getSupplierByCode(stub, supplierCode)
+		if err != nil {
+			return "", err
+		}
+		if supplier != nil && checkCallerMsp(stub, []string{supplier.MspId}) == nil {
+			return partySupplier, nil
+		}
+	}
+
+	callerMspId, _ := getCallerMspId(stub)
+	return "", fmt.Errorf("organization %s is neither buyer nor supplier %s", callerMspId, supplierCode)
+}
+
+/*
+ * Function to register the organization of a supplier, only buyer organizations may register suppliers.
+ * A supplier registered again is moved to the given organization.
+ * 1st - supplier code, 2nd - MSP id of the supplier's organization
+ */
+func (cc *Invoice) registerSupplier(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 2 || args[0] == "" || args[1] == "" {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	config, err := getChaincodeConfig(stub)
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if err := checkCallerMsp(stub, config.BuyerMspIds); err != nil {
+		return Error(http.StatusForbidden, "Not allowed to register suppliers: "+err.Error())
+	}
+
+	supplier := &Supplier{
+		SupplierCode:     args[0],
+		MspId:            args[1],
+		IsSupplierObject: true,
+	}
+
+	// convert to byte
+	supplierInBytes, _ := json.Marshal(supplier)
+
+	// write supplier to BC
+	if err := stub.PutState("SUP-"+args[0], supplierInBytes); err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return Success(http.StatusCreated, "Supplier Registered Successsfully!", nil)
+}
+
+/*
+ * Function to get supplier by code.
+ */
+func (cc *Invoice) getSupplier(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+
+	// check total parameters
+	if len(args) != 1 {
+		return Error(http.StatusNotAcceptable, "Invalid parameters!")
+	}
+
+	supplierInBytes, err := stub.GetState("SUP-" + args[0])
+	if err != nil {
+		return Error(http.StatusInternalServerError, err.Error())
+	}
+	if supplierInBytes == nil {
+		return Error(http.StatusNotFound, "Supplier "+args[0]+" not found")
+	}
+
+	return Success(http.StatusOK, "OK", supplierInBytes)
+}